@@ -0,0 +1,222 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/internal/service"
+)
+
+// writeConfigFile writes a YAML configuration file and returns its path
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.KVStore.URI != "localhost:6379" {
+		t.Errorf("kvstore.uri = %q, want the host and port", cfg.KVStore.URI)
+	}
+
+	svc := cfg.ServiceConfig()
+	defaults := service.DefaultConfig()
+	if svc.Provider != defaults.Provider {
+		t.Errorf("provider = %+v, want the service defaults %+v", svc.Provider, defaults.Provider)
+	}
+	if svc.Queues != defaults.Queues || svc.Wait != defaults.Wait || svc.Expiration != defaults.Expiration {
+		t.Errorf("service config = %+v, want the service defaults", svc)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `
+http:
+  addr: ":9000"
+  write_timeout: 30s
+inbox:
+  ttl: 2h
+orders:
+  status_mapping:
+    Em revisão: ignore
+`)
+	t.Setenv("HTTP_ADDR", ":9100")
+	t.Setenv("WAIT_MAX", "20s")
+	t.Setenv("ORDERS_STATUS_MAPPING", "Fraude=reject")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HTTP.Addr != ":9100" {
+		t.Errorf("http.addr = %q, want the environment value", cfg.HTTP.Addr)
+	}
+	if cfg.Inbox.TTL != 2*time.Hour || cfg.HTTP.WriteTimeout != 30*time.Second {
+		t.Errorf("inbox.ttl = %s, http.write_timeout = %s, want the file values", cfg.Inbox.TTL, cfg.HTTP.WriteTimeout)
+	}
+	if cfg.Wait.Max != 20*time.Second {
+		t.Errorf("wait.max = %s, want the environment value", cfg.Wait.Max)
+	}
+	for status, want := range map[string]string{
+		"Aberto":     string(service.PaymentStatusPending),
+		"Em revisão": service.OrderStatusIgnore,
+		"Fraude":     service.OrderStatusReject,
+	} {
+		if got := cfg.Orders.StatusMapping[status]; got != want {
+			t.Errorf("orders.status_mapping[%q] = %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		env      map[string]string
+		wantErrs []string
+	}{
+		{
+			name:     "unparsable environment values are all reported",
+			env:      map[string]string{"KVSTORE_PORT": "abc", "PROVIDER_SUCCESS_RATE": "often", "INBOX_TTL": "1 day"},
+			wantErrs: []string{"KVSTORE_PORT", "PROVIDER_SUCCESS_RATE", "INBOX_TTL"},
+		},
+		{
+			name:     "invalid mapping entry",
+			env:      map[string]string{"ORDERS_STATUS_MAPPING": "Aberto"},
+			wantErrs: []string{`"Aberto" is not a key=value pair`},
+		},
+		{
+			name:     "invalid values are validated",
+			env:      map[string]string{"PROVIDER_SUCCESS_RATE": "2", "KVSTORE_DRIVER": "etcd"},
+			wantErrs: []string{"provider.success_rate", "kvstore.driver"},
+		},
+		{
+			name:     "unparsable file",
+			file:     "http: [",
+			wantErrs: []string{"parsing config file"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			path := ""
+			if tt.file != "" {
+				path = writeConfigFile(t, tt.file)
+			}
+
+			_, err := LoadConfig(path)
+			if err == nil {
+				t.Fatal("LoadConfig succeeded")
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil || !strings.Contains(err.Error(), "reading config file") {
+		t.Errorf("missing file: err = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{name: "defaults are valid", modify: func(*Config) {}},
+		{
+			name:    "postgres requires a DSN",
+			modify:  func(c *Config) { c.Repository.Driver = "postgres" },
+			wantErr: "repository.postgres.dsn is required",
+		},
+		{
+			name:    "queues are not shared",
+			modify:  func(c *Config) { c.Queues.Failed = c.Queues.Paid },
+			wantErr: "must not share the queue",
+		},
+		{
+			name: "cluster queues share a hash tag",
+			modify: func(c *Config) {
+				c.KVStore.ClusterMode = true
+				c.Queues.Pending, c.Queues.Processing = "{a}:pending", "{b}:processing"
+			},
+			wantErr: "must share a hash tag",
+		},
+		{
+			name: "cluster queues with a shared hash tag",
+			modify: func(c *Config) {
+				c.KVStore.ClusterMode = true
+				c.Queues.Pending, c.Queues.Processing = "{payments}:pending", "{payments}:processing"
+			},
+		},
+		{
+			name:    "username requires a password",
+			modify:  func(c *Config) { c.KVStore.Username = "payments" },
+			wantErr: "kvstore.password is required",
+		},
+		{
+			name:    "unknown order status action",
+			modify:  func(c *Config) { c.Orders.StatusMapping = map[string]string{"Aberto": "maybe"} },
+			wantErr: `orders.status_mapping["Aberto"]`,
+		},
+		{
+			name:    "unsupported limit currency",
+			modify:  func(c *Config) { c.Limits.MaxAmounts = map[string]string{"XYZ": "10"} },
+			wantErr: "limits.max_amounts",
+		},
+		{
+			name:    "negative pending TTL",
+			modify:  func(c *Config) { c.Expiration.PendingTTL = -time.Second },
+			wantErr: "expiration.pending_ttl",
+		},
+		{
+			name:    "wait longer than the write timeout",
+			modify:  func(c *Config) { c.Wait.Max = c.HTTP.WriteTimeout },
+			wantErr: "wait.max must be shorter than http.write_timeout",
+		},
+		{
+			name:    "negative provider latency",
+			modify:  func(c *Config) { c.Provider.Latency = -time.Second },
+			wantErr: "provider.latency",
+		},
+		{
+			name:    "cloudevents require a source",
+			modify:  func(c *Config) { c.Events.Format, c.Events.Source = service.EventFormatCloudEvents, "" },
+			wantErr: "events.source",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.KVStore.URI = "localhost:6379"
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate err = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,47 +2,53 @@ package main
 
 import (
 	"context"
-	"os"
 	"strings"
 
 	"github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore"
 	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
 )
 
-// initializeApp initializes the application by loading the configuration, connecting to the datastore,
+// initializeApp initializes the application by initializing the logger, connecting to the datastores,
 // and subscribing to the Redis channel for receiving messages.
-// It returns a pointer to the RedisStore and an error if any.
+// It returns the RedisStore, the repository selected by the configuration and an error if any.
+func initializeApp(configs Config) (datastore.RedisStore, datastore.PaymentRepository, error) {
+	logger.InitializeLoggerWithOptions(configs.Log.Level, configs.Log.Format)
 
-func initializeApp() (datastore.RedisStore, error) {
-	logger.InitializeLogger()
-
-	// Load the configuration
-	logger.Info("Loading configuration...")
-	configs, err := LoadConfig()
-	if err != nil {
-		logger.Error(err.Error())
-		return nil, err
+	var redisStore datastore.RedisStore
+	var err error
+	if configs.KVStore.Driver == "memory" {
+		logger.Info("Using in-memory datastore, data is lost on exit")
+		redisStore = datastore.NewMemoryStore()
+	} else {
+		logger.Info("Connecting to datastore...")
+		redisStore, err = datastore.NewRedisStore(configs.RedisOptions())
+		if err != nil {
+			// handle error
+			logger.Error(err.Error())
+			return nil, nil, err
+		}
 	}
 
-	logger.Info("Connecting to datastore...")
-
-	redisStore, err := datastore.NewRedisStore(configs.KVSURI, "", configs.KVSDB)
-	if err != nil {
-		// handle error
-		logger.Error(err.Error())
-		return nil, err
+	var repository datastore.PaymentRepository = redisStore
+	if configs.Repository.Driver == "postgres" {
+		logger.Info("Connecting to payment repository...")
+		repository, err = datastore.NewPostgresRepository(configs.PostgresOptions())
+		if err != nil {
+			logger.Error(err.Error())
+			return nil, nil, err
+		}
 	}
 
-	// Subscribe to the Redis channel if APP_LOG_LEVEL is set to debug
-	if strings.ToLower(os.Getenv("APP_LOG_LEVEL")) == "debug" {
+	// Subscribe to the Redis channel if the log level is set to debug
+	if strings.ToLower(configs.Log.Level) == "debug" {
 		err = debugChannelSubscriber(redisStore)
 		if err != nil {
 			logger.Error(err.Error())
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	return redisStore, nil
+	return redisStore, repository, nil
 }
 
 func debugChannelSubscriber(redisStore datastore.RedisStore) error {
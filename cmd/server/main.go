@@ -1,6 +1,8 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"os"
 
 	"github.com/SOAT1StackGoLang/msvc-payments/internal/endpoint"
@@ -10,16 +12,32 @@ import (
 )
 
 // main is the entry point of the program.
-// It initializes the Redis store, creates the service, sets up the endpoints,
+// It loads the configuration, initializes the Redis store and the payment repository, creates the service, sets up the endpoints,
 // creates an HTTP handler, and starts the HTTP server.
 func main() {
-	redisStore, err := initializeApp()
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path to an optional YAML configuration file")
+	printConfig := flag.Bool("print-config", false, "print the resolved configuration with secrets masked and exit")
+	flag.Parse()
+
+	// Load the configuration
+	configs, err := LoadConfig(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	if *printConfig {
+		fmt.Print(configs.String())
+		return
+	}
+
+	redisStore, repository, err := initializeApp(configs)
 	if err != nil {
 		os.Exit(1)
 	}
 
 	// Create the service
-	svc := service.NewService(redisStore)
+	svc := service.NewService(repository, redisStore, redisStore, configs.ServiceConfig())
 
 	// Start processing payments background service
 	go svc.StartProcessingPayments()
@@ -27,12 +45,18 @@ func main() {
 	// Start consuming payments background service
 	go svc.StartConsumingPaymentsRequests()
 
+	// Start expiring payments background service
+	go svc.StartExpiringPayments()
+
 	// Create the endpoints using MakeEndpoints and CreatePaymentEndpoint from the service package
 	endpoints := endpoint.MakeEndpoints(svc)
 
 	httpHandler := transport.NewHTTPHandler(endpoints)
 
 	// Start the HTTP server
-	logger.Info("Starting HTTP server...")
-	transport.NewHTTPServer(":8080", httpHandler)
+	logger.Info("Starting HTTP server on " + configs.HTTP.Addr + "...")
+	if err := transport.NewHTTPServer(configs.ServerOptions(), httpHandler); err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
 }
@@ -5,39 +5,57 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/SOAT1StackGoLang/msvc-payments/internal/service"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/api"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 )
 
 type Endpoints struct {
 	CreatePayment endpoint.Endpoint
 	GetPayment    endpoint.Endpoint
 	UpdatePayment endpoint.Endpoint
+	// CreatePaymentAttempt retries a failed payment
+	CreatePaymentAttempt endpoint.Endpoint
+	// CapturePayment and VoidPayment complete or release an authorized payment
+	CapturePayment endpoint.Endpoint
+	VoidPayment    endpoint.Endpoint
+	// GetPaymentsReport sums the payments by currency
+	GetPaymentsReport endpoint.Endpoint
+	// Quarantine endpoints inspect, replay and discard the messages that could not be applied
+	ListQuarantinedMessages   endpoint.Endpoint
+	ReplayQuarantinedMessage  endpoint.Endpoint
+	DiscardQuarantinedMessage endpoint.Endpoint
 	// Add other endpoints here
 }
 
 // Implement MakeCreatePaymentHandler
+// ?wait=10s holds the response until the payment is final or the wait elapses
 func MakeCreatePaymentHandler(e endpoint.Endpoint) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		request := service.CreatePaymentRequest{} // Use the CreatePaymentRequest type from the service package
 		err := json.NewDecoder(r.Body).Decode(&request)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeBadRequest(w, r, err)
 			return
 		}
-		if request.Payment.ID == uuid.Nil {
-			err = errors.New("error on decoding request body")
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		request.Wait, err = waitParam(r)
+		if err != nil {
+			writeBadRequest(w, r, err)
 			return
 		}
-
 		response, err := e(r.Context(), request)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			WriteError(w, r, err)
 			return
 		}
 
@@ -46,39 +64,49 @@ func MakeCreatePaymentHandler(e endpoint.Endpoint) http.HandlerFunc {
 
 		// Encode the response
 		if err := json.NewEncoder(w).Encode(createPaymentResponse); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			WriteError(w, r, err)
 			return
 		}
 	}
 }
 
 // Implement MakeGetPaymentHandler
+// The payment ID is read from the path, /payments/{id}, or from the JSON body on /payments.
+// ?wait_for=final holds the response until the payment is final, for at most ?wait when it is given.
 func MakeGetPaymentHandler(e endpoint.Endpoint) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		request := service.GetPaymentRequest{} // Use the GetPaymentRequest type from the service package
-		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if id, ok := mux.Vars(r)["id"]; ok {
+			paymentID, err := uuid.Parse(id)
+			if err != nil {
+				writeBadRequest(w, r, err)
+				return
+			}
+			request.PaymentID = paymentID
+		} else if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeBadRequest(w, r, err)
 			return
 		}
-
-		if request.PaymentID == uuid.Nil {
-			err := errors.New("error on decoding request body")
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		wait, err := waitParam(r)
+		if err != nil {
+			writeBadRequest(w, r, err)
 			return
 		}
+		request.WaitFor, request.Wait = r.URL.Query().Get("wait_for"), wait
 
 		response, err := e(r.Context(), request)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			WriteError(w, r, err)
 			return
 		}
 
 		// Cast the response to the GetPaymentResponse type from the service package
 		getPaymentResponse := response.(service.GetPaymentResponse)
+		w.Header().Set("ETag", formatETag(getPaymentResponse.Payment.Version))
 
 		// Encode the response
 		if err := json.NewEncoder(w).Encode(getPaymentResponse); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			WriteError(w, r, err)
 			return
 		}
 	}
@@ -89,32 +117,319 @@ func MakeUpdatePaymentHandler(e endpoint.Endpoint) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		request := service.UpdatePaymentRequest{} // Use the UpdatePaymentRequest type from the service package
 		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeBadRequest(w, r, err)
+			return
+		}
+
+		ifMatch, err := ifMatchVersion(r)
+		if err != nil {
+			WriteProblem(w, r, http.StatusPreconditionFailed, api.ErrorCodeVersionConflict, err.Error())
 			return
 		}
+		request.IfMatch = ifMatch
 
 		response, err := e(r.Context(), request)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			WriteError(w, r, err)
 			return
 		}
 
 		// Cast the response to the UpdatePaymentResponse type from the service package
 		updatePaymentResponse := response.(service.UpdatePaymentResponse)
+		w.Header().Set("ETag", formatETag(updatePaymentResponse.Version))
 
 		// Encode the response
 		if err := json.NewEncoder(w).Encode(updatePaymentResponse); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			WriteError(w, r, err)
+			return
+		}
+	}
+}
+
+// Implement MakeCreatePaymentAttemptHandler
+// The payment ID is read from the path, /payments/{id}/attempts, the body is empty
+func MakeCreatePaymentAttemptHandler(e endpoint.Endpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		paymentID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			writeBadRequest(w, r, err)
+			return
+		}
+		request := service.CreatePaymentAttemptRequest{PaymentID: paymentID}
+
+		ifMatch, err := ifMatchVersion(r)
+		if err != nil {
+			WriteProblem(w, r, http.StatusPreconditionFailed, api.ErrorCodeVersionConflict, err.Error())
+			return
+		}
+		request.IfMatch = ifMatch
+
+		response, err := e(r.Context(), request)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		// Cast the response to the CreatePaymentAttemptResponse type from the service package
+		attemptResponse := response.(service.CreatePaymentAttemptResponse)
+		w.Header().Set("ETag", formatETag(attemptResponse.Version))
+
+		// Encode the response
+		if err := json.NewEncoder(w).Encode(attemptResponse); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+}
+
+// Implement MakeCapturePaymentHandler
+// The payment ID is read from the path, /payments/{id}/capture, the optional body {"amount": ...} captures part of the price
+func MakeCapturePaymentHandler(e endpoint.Endpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		paymentID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			writeBadRequest(w, r, err)
+			return
+		}
+		request := service.CapturePaymentRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+			writeBadRequest(w, r, err)
+			return
+		}
+		request.PaymentID = paymentID
+
+		ifMatch, err := ifMatchVersion(r)
+		if err != nil {
+			WriteProblem(w, r, http.StatusPreconditionFailed, api.ErrorCodeVersionConflict, err.Error())
+			return
+		}
+		request.IfMatch = ifMatch
+
+		response, err := e(r.Context(), request)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		// Cast the response to the CapturePaymentResponse type from the service package
+		captureResponse := response.(service.CapturePaymentResponse)
+		w.Header().Set("ETag", formatETag(captureResponse.Version))
+
+		// Encode the response
+		if err := json.NewEncoder(w).Encode(captureResponse); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+}
+
+// Implement MakeVoidPaymentHandler
+// The payment ID is read from the path, /payments/{id}/void, the body is empty
+func MakeVoidPaymentHandler(e endpoint.Endpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		paymentID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			writeBadRequest(w, r, err)
+			return
+		}
+		request := service.VoidPaymentRequest{PaymentID: paymentID}
+
+		ifMatch, err := ifMatchVersion(r)
+		if err != nil {
+			WriteProblem(w, r, http.StatusPreconditionFailed, api.ErrorCodeVersionConflict, err.Error())
+			return
+		}
+		request.IfMatch = ifMatch
+
+		response, err := e(r.Context(), request)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		// Cast the response to the VoidPaymentResponse type from the service package
+		voidResponse := response.(service.VoidPaymentResponse)
+		w.Header().Set("ETag", formatETag(voidResponse.Version))
+
+		// Encode the response
+		if err := json.NewEncoder(w).Encode(voidResponse); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+}
+
+// Implement MakeGetPaymentsReportHandler
+func MakeGetPaymentsReportHandler(e endpoint.Endpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response, err := e(r.Context(), service.GetPaymentsReportRequest{})
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		// Cast the response to the GetPaymentsReportResponse type from the service package
+		reportResponse := response.(service.GetPaymentsReportResponse)
+
+		// Encode the response
+		if err := json.NewEncoder(w).Encode(reportResponse); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+}
+
+// Implement MakeListQuarantinedMessagesHandler
+func MakeListQuarantinedMessagesHandler(e endpoint.Endpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response, err := e(r.Context(), service.ListQuarantinedMessagesRequest{})
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		// Cast the response to the ListQuarantinedMessagesResponse type from the service package
+		listResponse := response.(service.ListQuarantinedMessagesResponse)
+
+		// Encode the response
+		if err := json.NewEncoder(w).Encode(listResponse); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+}
+
+// Implement MakeReplayQuarantinedMessageHandler
+// The optional body {"payload": ...} replaces the quarantined payload, either as a JSON string or as the message itself
+func MakeReplayQuarantinedMessageHandler(e endpoint.Endpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			writeBadRequest(w, r, err)
+			return
+		}
+		request := service.ReplayQuarantinedMessageRequest{ID: id}
+
+		var body struct {
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			writeBadRequest(w, r, err)
+			return
+		}
+		if len(body.Payload) > 0 {
+			payload := string(body.Payload)
+			// a JSON string holds the raw payload, anything else is the message itself
+			_ = json.Unmarshal(body.Payload, &payload)
+			request.Payload = &payload
+		}
+
+		response, err := e(r.Context(), request)
+		if err != nil {
+			WriteError(w, r, err)
 			return
 		}
+
+		// Cast the response to the ReplayQuarantinedMessageResponse type from the service package
+		replayResponse := response.(service.ReplayQuarantinedMessageResponse)
+		if replayResponse.Quarantined != nil {
+			// the message still can't be applied and was quarantined again
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+
+		// Encode the response
+		if err := json.NewEncoder(w).Encode(replayResponse); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+}
+
+// Implement MakeDiscardQuarantinedMessageHandler
+func MakeDiscardQuarantinedMessageHandler(e endpoint.Endpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			writeBadRequest(w, r, err)
+			return
+		}
+
+		response, err := e(r.Context(), service.DiscardQuarantinedMessageRequest{ID: id})
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		// Cast the response to the DiscardQuarantinedMessageResponse type from the service package
+		discardResponse := response.(service.DiscardQuarantinedMessageResponse)
+
+		// Encode the response
+		if err := json.NewEncoder(w).Encode(discardResponse); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+}
+
+// waitParam reads the wait query parameter, a duration such as 10s, zero when it is absent
+func waitParam(r *http.Request) (time.Duration, error) {
+	value := r.URL.Query().Get("wait")
+	if value == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid wait %q: %w", value, err)
 	}
+	return wait, nil
+}
+
+// formatETag renders a payment version as a strong entity tag
+func formatETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// ifMatchVersion returns the payment version of the If-Match header, nil when it is absent or "*"
+func ifMatchVersion(r *http.Request) (*int64, error) {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || ifMatch == "*" {
+		return nil, nil
+	}
+	version, err := parseETag(ifMatch)
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// parseETag reads the payment version of an If-Match header, weak tags are accepted
+func parseETag(tag string) (int64, error) {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+	unquoted, err := strconv.Unquote(tag)
+	if err != nil {
+		unquoted = tag
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid If-Match header %q", tag)
+	}
+	return version, nil
 }
 
 func MakeEndpoints(s service.Service) Endpoints {
 	return Endpoints{
-		CreatePayment: makeCreatePaymentEndpoint(s),
-		GetPayment:    makeGetPaymentEndpoint(s),
-		UpdatePayment: makeUpdatePaymentEndpoint(s),
+		CreatePayment:     makeCreatePaymentEndpoint(s),
+		GetPayment:        makeGetPaymentEndpoint(s),
+		UpdatePayment:     makeUpdatePaymentEndpoint(s),
+		GetPaymentsReport: makeGetPaymentsReportEndpoint(s),
+
+		CreatePaymentAttempt: makeCreatePaymentAttemptEndpoint(s),
+		CapturePayment:       makeCapturePaymentEndpoint(s),
+		VoidPayment:          makeVoidPaymentEndpoint(s),
+
+		ListQuarantinedMessages:   makeListQuarantinedMessagesEndpoint(s),
+		ReplayQuarantinedMessage:  makeReplayQuarantinedMessageEndpoint(s),
+		DiscardQuarantinedMessage: makeDiscardQuarantinedMessageEndpoint(s),
 		// Initialize other endpoints here
 	}
 }
@@ -145,3 +460,66 @@ func makeUpdatePaymentEndpoint(s service.Service) endpoint.Endpoint {
 		return resp, err
 	}
 }
+
+// Implement makeCreatePaymentAttemptEndpoint
+func makeCreatePaymentAttemptEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(service.CreatePaymentAttemptRequest)
+		resp, err := s.CreatePaymentAttempt(ctx, req)
+		return resp, err
+	}
+}
+
+// Implement makeCapturePaymentEndpoint
+func makeCapturePaymentEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(service.CapturePaymentRequest)
+		resp, err := s.CapturePayment(ctx, req)
+		return resp, err
+	}
+}
+
+// Implement makeVoidPaymentEndpoint
+func makeVoidPaymentEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(service.VoidPaymentRequest)
+		resp, err := s.VoidPayment(ctx, req)
+		return resp, err
+	}
+}
+
+// Implement makeGetPaymentsReportEndpoint
+func makeGetPaymentsReportEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(service.GetPaymentsReportRequest)
+		resp, err := s.GetPaymentsReport(ctx, req)
+		return resp, err
+	}
+}
+
+// Implement makeListQuarantinedMessagesEndpoint
+func makeListQuarantinedMessagesEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(service.ListQuarantinedMessagesRequest)
+		resp, err := s.ListQuarantinedMessages(ctx, req)
+		return resp, err
+	}
+}
+
+// Implement makeReplayQuarantinedMessageEndpoint
+func makeReplayQuarantinedMessageEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(service.ReplayQuarantinedMessageRequest)
+		resp, err := s.ReplayQuarantinedMessage(ctx, req)
+		return resp, err
+	}
+}
+
+// Implement makeDiscardQuarantinedMessageEndpoint
+func makeDiscardQuarantinedMessageEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(service.DiscardQuarantinedMessageRequest)
+		resp, err := s.DiscardQuarantinedMessage(ctx, req)
+		return resp, err
+	}
+}
@@ -0,0 +1,100 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/internal/service"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/api"
+	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+)
+
+// problemContentType is the media type of RFC 7807 responses
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 error response, Code is the stable error code clients branch on
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	// Errors lists the invalid fields of validation_failed problems
+	Errors []service.FieldError `json:"errors,omitempty"`
+}
+
+// errorStatuses maps the service errors to their status and code, the first match wins
+var errorStatuses = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{service.ErrValidationFailed, http.StatusUnprocessableEntity, api.ErrorCodeValidationFailed},
+	{service.ErrPaymentNotFound, http.StatusNotFound, api.ErrorCodePaymentNotFound},
+	{service.ErrPaymentAlreadyExists, http.StatusConflict, api.ErrorCodePaymentAlreadyExists},
+	{service.ErrOrderHasActivePayment, http.StatusConflict, api.ErrorCodeOrderHasActivePayment},
+	{service.ErrInvalidTransition, http.StatusConflict, api.ErrorCodeInvalidTransition},
+	{service.ErrCaptureNotSupported, http.StatusConflict, api.ErrorCodeCaptureNotSupported},
+	{service.ErrVersionConflict, http.StatusPreconditionFailed, api.ErrorCodeVersionConflict},
+	{service.ErrQuarantinedMessageNotFound, http.StatusNotFound, api.ErrorCodeQuarantinedMessageNotFound},
+}
+
+// WriteError answers with the problem matching err, unknown errors are internal errors
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		writeProblem(w, r, Problem{
+			Status: http.StatusUnprocessableEntity,
+			Code:   api.ErrorCodeValidationFailed,
+			Detail: err.Error(),
+			Errors: validationErr.Fields,
+		})
+		return
+	}
+	for _, mapping := range errorStatuses {
+		if errors.Is(err, mapping.err) {
+			WriteProblem(w, r, mapping.status, mapping.code, err.Error())
+			return
+		}
+	}
+	logger.Error(err.Error())
+	WriteProblem(w, r, http.StatusInternalServerError, api.ErrorCodeInternal, err.Error())
+}
+
+// writeBadRequest answers with an invalid_request problem, used when the request can't be read
+func writeBadRequest(w http.ResponseWriter, r *http.Request, err error) {
+	WriteProblem(w, r, http.StatusBadRequest, api.ErrorCodeInvalidRequest, err.Error())
+}
+
+// WriteProblem answers with an RFC 7807 problem of the given status and code
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, code string, detail string) {
+	writeProblem(w, r, Problem{Status: status, Code: code, Detail: detail})
+}
+
+// writeProblem fills the type, title and instance of the problem and writes it
+func writeProblem(w http.ResponseWriter, r *http.Request, problem Problem) {
+	problem.Type = "/problems/" + problem.Code
+	problem.Title = http.StatusText(problem.Status)
+	problem.Instance = r.URL.Path
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+// NotFoundHandler answers unknown routes with a not_found problem
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteProblem(w, r, http.StatusNotFound, api.ErrorCodeNotFound, "no route for "+r.URL.Path)
+	})
+}
+
+// MethodNotAllowedHandler answers known routes called with another method with a method_not_allowed problem
+func MethodNotAllowedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteProblem(w, r, http.StatusMethodNotAllowed, api.ErrorCodeMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+	})
+}
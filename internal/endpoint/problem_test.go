@@ -0,0 +1,113 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/internal/service"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/api"
+	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+)
+
+// writeTestError answers a request of path with err and decodes the problem
+func writeTestError(t *testing.T, path string, err error) (*httptest.ResponseRecorder, Problem) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	WriteError(rec, httptest.NewRequest(http.MethodGet, path, nil), err)
+
+	var problem Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("invalid problem %q: %v", rec.Body.String(), err)
+	}
+	return rec, problem
+}
+
+func TestWriteError(t *testing.T) {
+	if logger.ErrorLogger == nil {
+		logger.InitializeLoggerWithOptions("error", "logfmt")
+	}
+
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{service.ErrPaymentNotFound, http.StatusNotFound, api.ErrorCodePaymentNotFound},
+		{service.ErrPaymentAlreadyExists, http.StatusConflict, api.ErrorCodePaymentAlreadyExists},
+		{service.ErrOrderHasActivePayment, http.StatusConflict, api.ErrorCodeOrderHasActivePayment},
+		{service.ErrInvalidTransition, http.StatusConflict, api.ErrorCodeInvalidTransition},
+		{service.ErrCaptureNotSupported, http.StatusConflict, api.ErrorCodeCaptureNotSupported},
+		{service.ErrVersionConflict, http.StatusPreconditionFailed, api.ErrorCodeVersionConflict},
+		{service.ErrQuarantinedMessageNotFound, http.StatusNotFound, api.ErrorCodeQuarantinedMessageNotFound},
+		{service.ErrValidationFailed, http.StatusUnprocessableEntity, api.ErrorCodeValidationFailed},
+		{errors.New("unexpected end of JSON input"), http.StatusInternalServerError, api.ErrorCodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantCode, func(t *testing.T) {
+			// the service wraps its sentinel errors
+			err := fmt.Errorf("failed loading payment: %w", tt.err)
+			rec, problem := writeTestError(t, "/payments/1", err)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != problemContentType {
+				t.Errorf("Content-Type = %q, want %q", ct, problemContentType)
+			}
+			want := Problem{
+				Type:     "/problems/" + tt.wantCode,
+				Title:    http.StatusText(tt.wantStatus),
+				Status:   tt.wantStatus,
+				Detail:   err.Error(),
+				Instance: "/payments/1",
+				Code:     tt.wantCode,
+			}
+			if !reflect.DeepEqual(problem, want) {
+				t.Errorf("problem = %+v, want %+v", problem, want)
+			}
+		})
+	}
+}
+
+func TestWriteErrorListsInvalidFields(t *testing.T) {
+	fields := []service.FieldError{
+		{Field: "payment.ID", Message: "is required"},
+		{Field: "payment.Price", Message: "must be positive"},
+	}
+	rec, problem := writeTestError(t, "/payments", fmt.Errorf("invalid payment: %w", &service.ValidationError{Fields: fields}))
+
+	if rec.Code != http.StatusUnprocessableEntity || problem.Code != api.ErrorCodeValidationFailed {
+		t.Errorf("answered %d %s, want 422 %s", rec.Code, problem.Code, api.ErrorCodeValidationFailed)
+	}
+	if !reflect.DeepEqual(problem.Errors, fields) {
+		t.Errorf("errors = %+v, want %+v", problem.Errors, fields)
+	}
+}
+
+func TestUnknownRoutesAreProblems(t *testing.T) {
+	tests := []struct {
+		handler    http.Handler
+		wantStatus int
+		wantCode   string
+	}{
+		{NotFoundHandler(), http.StatusNotFound, api.ErrorCodeNotFound},
+		{MethodNotAllowedHandler(), http.StatusMethodNotAllowed, api.ErrorCodeMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		tt.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/nowhere", nil))
+
+		var problem Problem
+		if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("invalid problem %q: %v", rec.Body.String(), err)
+		}
+		if rec.Code != tt.wantStatus || problem.Code != tt.wantCode || problem.Instance != "/nowhere" {
+			t.Errorf("answered %d %+v, want %d %s", rec.Code, problem, tt.wantStatus, tt.wantCode)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+	"github.com/google/uuid"
+)
+
+// Attempt is a charge of the payment at the provider
+type Attempt struct {
+	// Number counts the attempts of the payment from 1
+	Number int
+	// ProviderReference identifies the charge at the provider
+	ProviderReference string `json:",omitempty"`
+	// Outcome is paid, authorized or failed
+	Outcome PaymentStatus
+	// DeclineCode is the code given by the provider, DeclineReason its normalized reason
+	DeclineCode   string        `json:",omitempty"`
+	DeclineReason DeclineReason `json:",omitempty"`
+	StartedAt     time.Time
+	FinishedAt    time.Time
+}
+
+type CreatePaymentAttemptRequest struct {
+	PaymentID uuid.UUID `json:"payment_id"`
+	// IfMatch is the version the payment must still be at, taken from the If-Match header
+	IfMatch *int64 `json:"-"`
+}
+
+type CreatePaymentAttemptResponse struct {
+	PaymentID uuid.UUID     `json:"payment_id"`
+	Status    PaymentStatus `json:"status"`
+	// Attempts is the number of attempts made before this one
+	Attempts int   `json:"attempts"`
+	Version  int64 `json:"version"`
+}
+
+// chargePayment charges a pending payment with the provider, or authorizes it when its capture is manual,
+// stores the attempt and its outcome, then notifies the status and places the payment in the paid,
+// authorized or failed queue. The payment is reserved as processing before the provider is called.
+func (s *serviceImpl) chargePayment(ctx context.Context, paymentID uuid.UUID, expectedVersion *int64) (Payment, error) {
+	payment, _, err := s.loadPayment(ctx, paymentID)
+	if err != nil {
+		return Payment{}, err
+	}
+	if expectedVersion != nil && payment.Version != *expectedVersion {
+		return Payment{}, ErrVersionConflict
+	}
+	// only pending payments are charged
+	if err := checkTransition(payment.Status, PaymentStatusProcessing); err != nil {
+		return Payment{}, err
+	}
+	// the customer is gone, don't charge
+	if now := time.Now(); payment.expired(now) {
+		return s.expirePayment(ctx, paymentID, now)
+	}
+
+	payment, err = s.reserveCharge(ctx, payment)
+	if err != nil {
+		return Payment{}, err
+	}
+	// the provider is called once, outside of the compare-and-set retries
+	startedAt := time.Now()
+	result, err := s.charge(ctx, payment)
+	if err != nil {
+		s.releaseCharge(payment.ID)
+		return Payment{}, err
+	}
+	attempt := Attempt{
+		ProviderReference: result.Reference,
+		Outcome:           result.Status,
+		DeclineCode:       result.DeclineCode,
+		DeclineReason:     result.DeclineReason,
+		StartedAt:         startedAt,
+		FinishedAt:        time.Now(),
+	}
+
+	payment, err = s.modifyPayment(ctx, paymentID, nil, func(p *Payment) error {
+		if err := checkTransition(p.Status, result.Status); err != nil {
+			return err
+		}
+		attempt.Number = len(p.Attempts) + 1
+		p.Attempts = append(p.Attempts, attempt)
+		p.Status = result.Status
+		p.DeclineReason = result.DeclineReason
+		p.ExpiresAt = nil
+		if p.Status == PaymentStatusAuthorized {
+			expiresAt := attempt.FinishedAt.Add(s.cfg.Authorization.CaptureWindow)
+			p.AuthorizationExpiresAt = &expiresAt
+		}
+		return nil
+	})
+	if err != nil {
+		return Payment{}, err
+	}
+
+	// notify channels of the payment status
+	err = s.publishStatusChanged(ctx, payment)
+	if err != nil {
+		logger.Error(err.Error())
+		return Payment{}, err
+	}
+	// add the payment to the paid, authorized or failed queue
+	queue := s.cfg.Queues.Paid
+	switch payment.Status {
+	case PaymentStatusFailed:
+		queue = s.cfg.Queues.Failed
+	case PaymentStatusAuthorized:
+		queue = s.cfg.Queues.Authorized
+	}
+	err = s.queue.LPush(ctx, queue, payment.ID.String())
+	if err != nil {
+		logger.Error(err.Error())
+		return Payment{}, err
+	}
+	return payment, nil
+}
+
+// reserveCharge moves the loaded payment to processing before the provider is called.
+// The move is a compare-and-set write on the loaded version, so of concurrent charges, such as the
+// worker and an update to paid, only one reaches the provider, the others fail with ErrVersionConflict.
+func (s *serviceImpl) reserveCharge(ctx context.Context, payment Payment) (Payment, error) {
+	return s.modifyPayment(ctx, payment.ID, &payment.Version, func(p *Payment) error {
+		if err := checkTransition(p.Status, PaymentStatusProcessing); err != nil {
+			return err
+		}
+		p.Status = PaymentStatusProcessing
+		return nil
+	})
+}
+
+// releaseCharge moves a payment reserved by reserveCharge back to pending when it was not charged,
+// so it can be charged again
+func (s *serviceImpl) releaseCharge(paymentID uuid.UUID) {
+	// the charge may have stopped because ctx was cancelled, the reservation is released anyway
+	_, err := s.modifyPayment(context.Background(), paymentID, nil, func(p *Payment) error {
+		if err := checkTransition(p.Status, PaymentStatusPending); err != nil {
+			return err
+		}
+		p.Status = PaymentStatusPending
+		return nil
+	})
+	if err != nil {
+		logger.Error("failed releasing charge of payment", paymentID.String(), err.Error())
+	}
+}
+
+// charge calls the provider within the configured timeout, authorizing the payments with manual capture.
+// A provider that fails or times out declines the charge with the provider_error or timeout reason,
+// only a stopping service returns an error.
+func (s *serviceImpl) charge(ctx context.Context, payment Payment) (ChargeResult, error) {
+	call, success := s.provider.Charge, PaymentStatusPaid
+	if payment.CaptureMode == CaptureModeManual {
+		if s.authorizer == nil {
+			return ChargeResult{}, ErrCaptureNotSupported
+		}
+		call, success = s.authorizer.Authorize, PaymentStatusAuthorized
+	}
+
+	chargeCtx, cancel := s.providerContext(ctx)
+	defer cancel()
+
+	result, err := call(chargeCtx, payment)
+	if err != nil {
+		// the payment stays in the processing queue and is charged again
+		if ctx.Err() != nil {
+			return ChargeResult{}, ctx.Err()
+		}
+		logger.Error("charging payment", payment.ID.String(), "failed:", err.Error())
+		return ChargeResult{Status: PaymentStatusFailed, DeclineReason: declineReasonOf(err)}, nil
+	}
+
+	switch result.Status {
+	case success:
+		result.DeclineCode, result.DeclineReason = "", ""
+	case PaymentStatusFailed:
+		if result.DeclineReason == "" {
+			result.DeclineReason = DeclineReasonProviderError
+		}
+	default:
+		return ChargeResult{}, fmt.Errorf("provider returned the unexpected status %q", result.Status)
+	}
+	return result, nil
+}
+
+// providerContext bounds a provider call by the configured timeout
+func (s *serviceImpl) providerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.cfg.Provider.Timeout > 0 {
+		return context.WithTimeout(ctx, s.cfg.Provider.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// restoreFailed moves a payment back to failed when its retry was refused after it became pending
+func (s *serviceImpl) restoreFailed(failed Payment) {
+	// the retry may have been refused because ctx was cancelled, the payment is restored anyway
+	_, err := s.modifyPayment(context.Background(), failed.ID, nil, func(p *Payment) error {
+		if err := checkTransition(p.Status, PaymentStatusFailed); err != nil {
+			return err
+		}
+		p.Status = PaymentStatusFailed
+		p.DeclineReason = failed.DeclineReason
+		p.ExpiresAt = failed.ExpiresAt
+		return nil
+	})
+	if err != nil {
+		logger.Error("failed restoring payment", failed.ID.String(), err.Error())
+	}
+}
+
+// CreatePaymentAttempt retries a failed payment, it goes back to pending and to the pending queue
+// to be charged again under the same ID
+func (s *serviceImpl) CreatePaymentAttempt(ctx context.Context, request CreatePaymentAttemptRequest) (CreatePaymentAttemptResponse, error) {
+	if err := s.validateCreatePaymentAttemptRequest(request); err != nil {
+		return CreatePaymentAttemptResponse{}, err
+	}
+	payment, _, err := s.loadPayment(ctx, request.PaymentID)
+	if err != nil {
+		return CreatePaymentAttemptResponse{}, err
+	}
+	if err := checkTransition(payment.Status, PaymentStatusPending); err != nil {
+		return CreatePaymentAttemptResponse{}, err
+	}
+
+	failed := payment
+	payment, err = s.modifyPayment(ctx, request.PaymentID, request.IfMatch, func(p *Payment) error {
+		if err := checkTransition(p.Status, PaymentStatusPending); err != nil {
+			return err
+		}
+		p.Status = PaymentStatusPending
+		p.DeclineReason = ""
+		p.ExpiresAt = s.pendingExpiry(time.Now())
+		return nil
+	})
+	if err != nil {
+		return CreatePaymentAttemptResponse{}, activePaymentError(err, failed.OrderID)
+	}
+	// another payment may have been created for the order after this one failed
+	if s.creator != nil {
+		if err := s.claimOrder(ctx, payment); err != nil {
+			s.restoreFailed(failed)
+			return CreatePaymentAttemptResponse{}, err
+		}
+	}
+
+	// move the payment from the failed queue back to the pending one
+	_ = s.queue.LREM(ctx, s.cfg.Queues.Failed, 0, payment.ID.String())
+	err = s.queue.LPush(ctx, s.cfg.Queues.Pending, payment.ID.String())
+	if err != nil {
+		logger.Error(err.Error())
+		return CreatePaymentAttemptResponse{}, err
+	}
+	err = s.publishStatusChanged(ctx, payment)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+
+	return CreatePaymentAttemptResponse{
+		PaymentID: payment.ID,
+		Status:    payment.Status,
+		Attempts:  len(payment.Attempts),
+		Version:   payment.Version,
+	}, nil
+}
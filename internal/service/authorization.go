@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/money"
+	"github.com/google/uuid"
+)
+
+type CapturePaymentRequest struct {
+	PaymentID uuid.UUID `json:"payment_id"`
+	// Amount captures part of the authorized price, the whole price is captured when it is nil
+	Amount *money.Money `json:"amount,omitempty"`
+	// IfMatch is the version the payment must still be at, taken from the If-Match header
+	IfMatch *int64 `json:"-"`
+}
+
+type CapturePaymentResponse struct {
+	PaymentID      uuid.UUID     `json:"payment_id"`
+	Status         PaymentStatus `json:"status"`
+	CapturedAmount money.Money   `json:"captured_amount"`
+	Version        int64         `json:"version"`
+}
+
+type VoidPaymentRequest struct {
+	PaymentID uuid.UUID `json:"payment_id"`
+	// IfMatch is the version the payment must still be at, taken from the If-Match header
+	IfMatch *int64 `json:"-"`
+}
+
+type VoidPaymentResponse struct {
+	PaymentID uuid.UUID     `json:"payment_id"`
+	Status    PaymentStatus `json:"status"`
+	Version   int64         `json:"version"`
+}
+
+// CapturePayment charges an authorized payment, all of its price or the requested amount
+func (s *serviceImpl) CapturePayment(ctx context.Context, request CapturePaymentRequest) (CapturePaymentResponse, error) {
+	if err := s.validateCapturePaymentRequest(request); err != nil {
+		return CapturePaymentResponse{}, err
+	}
+	payment, err := s.loadAuthorization(ctx, request.PaymentID, request.IfMatch, PaymentStatusCapturing)
+	if err != nil {
+		return CapturePaymentResponse{}, err
+	}
+
+	amount := payment.Price
+	if request.Amount != nil {
+		amount = *request.Amount
+		cmp, err := amount.Cmp(payment.Price)
+		if err != nil {
+			return CapturePaymentResponse{}, &ValidationError{Fields: []FieldError{
+				{Field: "amount.currency", Message: fmt.Sprintf("must be the currency of the payment, %s", money.NormalizeCurrency(payment.Price.Currency))},
+			}}
+		}
+		if cmp > 0 {
+			return CapturePaymentResponse{}, &ValidationError{Fields: []FieldError{
+				{Field: "amount.amount", Message: fmt.Sprintf("must not exceed the authorized %s", payment.Price)},
+			}}
+		}
+	}
+	amount, err = amount.Round()
+	if err != nil {
+		return CapturePaymentResponse{}, err
+	}
+
+	payment, err = s.reserveAuthorization(ctx, payment, PaymentStatusCapturing)
+	if err != nil {
+		return CapturePaymentResponse{}, err
+	}
+	providerCtx, cancel := s.providerContext(ctx)
+	defer cancel()
+	if err := s.authorizer.Capture(providerCtx, payment, amount); err != nil {
+		s.releaseAuthorization(payment.ID)
+		return CapturePaymentResponse{}, fmt.Errorf("failed capturing payment %s: %w", payment.ID, err)
+	}
+
+	payment, err = s.modifyPayment(ctx, request.PaymentID, nil, func(p *Payment) error {
+		if err := checkTransition(p.Status, PaymentStatusCaptured); err != nil {
+			return err
+		}
+		p.Status = PaymentStatusCaptured
+		p.CapturedAmount = &amount
+		p.AuthorizationExpiresAt = nil
+		return nil
+	})
+	if err != nil {
+		return CapturePaymentResponse{}, err
+	}
+
+	// move the payment from the authorized queue to the paid one
+	_ = s.queue.LREM(ctx, s.cfg.Queues.Authorized, 0, payment.ID.String())
+	err = s.queue.LPush(ctx, s.cfg.Queues.Paid, payment.ID.String())
+	if err != nil {
+		logger.Error(err.Error())
+		return CapturePaymentResponse{}, err
+	}
+	err = s.publishStatusChanged(ctx, payment)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+
+	return CapturePaymentResponse{
+		PaymentID:      payment.ID,
+		Status:         payment.Status,
+		CapturedAmount: amount,
+		Version:        payment.Version,
+	}, nil
+}
+
+// VoidPayment releases the funds held by an authorized payment
+func (s *serviceImpl) VoidPayment(ctx context.Context, request VoidPaymentRequest) (VoidPaymentResponse, error) {
+	if err := s.validateVoidPaymentRequest(request); err != nil {
+		return VoidPaymentResponse{}, err
+	}
+	payment, err := s.voidAuthorization(ctx, request.PaymentID, request.IfMatch)
+	if err != nil {
+		return VoidPaymentResponse{}, err
+	}
+	return VoidPaymentResponse{PaymentID: payment.ID, Status: payment.Status, Version: payment.Version}, nil
+}
+
+// voidAuthorization voids the authorization at the provider, then marks the payment as voided,
+// removes it from the authorized queue and notifies the status
+func (s *serviceImpl) voidAuthorization(ctx context.Context, paymentID uuid.UUID, expectedVersion *int64) (Payment, error) {
+	payment, err := s.loadAuthorization(ctx, paymentID, expectedVersion, PaymentStatusVoiding)
+	if err != nil {
+		return Payment{}, err
+	}
+	payment, err = s.reserveAuthorization(ctx, payment, PaymentStatusVoiding)
+	if err != nil {
+		return Payment{}, err
+	}
+
+	providerCtx, cancel := s.providerContext(ctx)
+	defer cancel()
+	if err := s.authorizer.Void(providerCtx, payment); err != nil {
+		s.releaseAuthorization(payment.ID)
+		return Payment{}, fmt.Errorf("failed voiding payment %s: %w", payment.ID, err)
+	}
+
+	payment, err = s.modifyPayment(ctx, paymentID, nil, func(p *Payment) error {
+		if err := checkTransition(p.Status, PaymentStatusVoided); err != nil {
+			return err
+		}
+		p.Status = PaymentStatusVoided
+		p.AuthorizationExpiresAt = nil
+		return nil
+	})
+	if err != nil {
+		return Payment{}, err
+	}
+
+	_ = s.queue.LREM(ctx, s.cfg.Queues.Authorized, 0, payment.ID.String())
+	err = s.publishStatusChanged(ctx, payment)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+	return payment, nil
+}
+
+// loadAuthorization reads a payment that must be authorized and able to move to the given status
+func (s *serviceImpl) loadAuthorization(ctx context.Context, paymentID uuid.UUID, expectedVersion *int64, to PaymentStatus) (Payment, error) {
+	payment, _, err := s.loadPayment(ctx, paymentID)
+	if err != nil {
+		return Payment{}, err
+	}
+	if expectedVersion != nil && payment.Version != *expectedVersion {
+		return Payment{}, ErrVersionConflict
+	}
+	if err := checkTransition(payment.Status, to); err != nil {
+		return Payment{}, err
+	}
+	// only payments with manual capture are authorized, and only by an authorizer
+	if s.authorizer == nil {
+		return Payment{}, ErrCaptureNotSupported
+	}
+	return payment, nil
+}
+
+// reserveAuthorization moves the loaded payment to capturing or voiding before the provider is called.
+// The move is a compare-and-set write on the loaded version, so of concurrent captures, voids and expiries
+// only one reaches the provider, the others fail with ErrVersionConflict.
+func (s *serviceImpl) reserveAuthorization(ctx context.Context, payment Payment, to PaymentStatus) (Payment, error) {
+	return s.modifyPayment(ctx, payment.ID, &payment.Version, func(p *Payment) error {
+		if err := checkTransition(p.Status, to); err != nil {
+			return err
+		}
+		p.Status = to
+		return nil
+	})
+}
+
+// releaseAuthorization moves a payment reserved by reserveAuthorization back to authorized
+// when the provider did not capture or void it, so it can be captured or voided again
+func (s *serviceImpl) releaseAuthorization(paymentID uuid.UUID) {
+	// the provider call may have failed because ctx was cancelled, the reservation is released anyway
+	_, err := s.modifyPayment(context.Background(), paymentID, nil, func(p *Payment) error {
+		if err := checkTransition(p.Status, PaymentStatusAuthorized); err != nil {
+			return err
+		}
+		p.Status = PaymentStatusAuthorized
+		return nil
+	})
+	if err != nil {
+		logger.Error("failed releasing authorization of payment", paymentID.String(), err.Error())
+	}
+}
+
+// expireAuthorizations voids the authorizations that were not captured within the capture window
+func (s *serviceImpl) expireAuthorizations(ctx context.Context, now time.Time) {
+	ids, err := s.queue.LRange(ctx, s.cfg.Queues.Authorized, 0, -1)
+	if err != nil {
+		logger.Error("failed listing authorized payments:", err.Error())
+		return
+	}
+
+	for _, id := range ids {
+		paymentID, err := uuid.Parse(id)
+		if err != nil {
+			logger.Error("invalid payment id in the authorized queue:", id)
+			_ = s.queue.LREM(ctx, s.cfg.Queues.Authorized, 0, id)
+			continue
+		}
+		payment, _, err := s.loadPayment(ctx, paymentID)
+		if err != nil {
+			logger.Error("failed reading authorized payment", id, err.Error())
+			continue
+		}
+		switch payment.Status {
+		case PaymentStatusAuthorized:
+		case PaymentStatusCapturing, PaymentStatusVoiding:
+			// being captured or voided right now
+			continue
+		default:
+			// captured or voided meanwhile
+			_ = s.queue.LREM(ctx, s.cfg.Queues.Authorized, 0, id)
+			continue
+		}
+		if payment.AuthorizationExpiresAt == nil || now.Before(*payment.AuthorizationExpiresAt) {
+			continue
+		}
+
+		logger.Info("voiding expired authorization of payment", id)
+		if _, err := s.voidAuthorization(ctx, paymentID, nil); err != nil {
+			logger.Error("failed voiding expired authorization of payment", id, err.Error())
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/money"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// testAuthorizer authorizes every payment and records the captures and voids
+type testAuthorizer struct {
+	testProvider
+	mu       sync.Mutex
+	captured []money.Money
+	voids    int
+}
+
+func (p *testAuthorizer) Authorize(ctx context.Context, payment Payment) (ChargeResult, error) {
+	return ChargeResult{Status: PaymentStatusAuthorized, Reference: "auth-1"}, nil
+}
+
+func (p *testAuthorizer) Capture(ctx context.Context, payment Payment, amount money.Money) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.captured = append(p.captured, amount)
+	return nil
+}
+
+func (p *testAuthorizer) Void(ctx context.Context, payment Payment) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.voids++
+	return nil
+}
+
+// authorizeTestPayment creates a payment of 19.90 BRL with manual capture and authorizes it
+func authorizeTestPayment(t *testing.T, s *serviceImpl) Payment {
+	t.Helper()
+	ctx := context.Background()
+	payment := Payment{
+		ID:          uuid.New(),
+		OrderID:     uuid.New(),
+		Price:       money.Money{Amount: decimal.RequireFromString("19.90"), Currency: "BRL"},
+		CaptureMode: CaptureModeManual,
+	}
+	if _, err := s.CreatePayment(ctx, CreatePaymentRequest{Payment: payment}); err != nil {
+		t.Fatal(err)
+	}
+	authorized, err := s.ProcessPayment(ctx, payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorized.Status != PaymentStatusAuthorized {
+		t.Fatalf("processed payment is %s, want authorized", authorized.Status)
+	}
+	return authorized
+}
+
+func TestCapturePayment(t *testing.T) {
+	cfg := DefaultConfig()
+	provider := &testAuthorizer{}
+	s, st := newTestService(t, cfg, WithProvider(provider))
+	ctx := context.Background()
+	payment := authorizeTestPayment(t, s)
+
+	if payment.AuthorizationExpiresAt == nil || payment.AuthorizationExpiresAt.Before(time.Now().Add(cfg.Authorization.CaptureWindow-time.Minute)) {
+		t.Errorf("authorization expires at %v, want in %s", payment.AuthorizationExpiresAt, cfg.Authorization.CaptureWindow)
+	}
+	if !inQueue(t, st, cfg.Queues.Authorized, payment.ID) {
+		t.Error("authorized payment is not in the authorized queue")
+	}
+
+	tooMuch := money.Money{Amount: decimal.RequireFromString("20.00"), Currency: "BRL"}
+	_, err := s.CapturePayment(ctx, CapturePaymentRequest{PaymentID: payment.ID, Amount: &tooMuch})
+	if !errors.Is(err, ErrValidationFailed) {
+		t.Fatalf("capture above the price: err = %v, want ErrValidationFailed", err)
+	}
+
+	part := money.Money{Amount: decimal.RequireFromString("10.00"), Currency: "BRL"}
+	captured, err := s.CapturePayment(ctx, CapturePaymentRequest{PaymentID: payment.ID, Amount: &part})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if captured.Status != PaymentStatusCaptured || !captured.CapturedAmount.Amount.Equal(part.Amount) {
+		t.Errorf("capture is %s of %s, want captured of %s", captured.Status, captured.CapturedAmount, part)
+	}
+	if len(provider.captured) != 1 || !provider.captured[0].Amount.Equal(part.Amount) {
+		t.Errorf("provider captured %v, want %s", provider.captured, part)
+	}
+	got, err := s.GetPayment(ctx, GetPaymentRequest{PaymentID: payment.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Payment.CapturedAmount == nil || !got.Payment.CapturedAmount.Amount.Equal(part.Amount) || got.Payment.AuthorizationExpiresAt != nil {
+		t.Errorf("stored capture %v expiring at %v, want %s without expiry", got.Payment.CapturedAmount, got.Payment.AuthorizationExpiresAt, part)
+	}
+	if inQueue(t, st, cfg.Queues.Authorized, payment.ID) || !inQueue(t, st, cfg.Queues.Paid, payment.ID) {
+		t.Error("captured payment is not moved to the paid queue")
+	}
+
+	if _, err := s.CapturePayment(ctx, CapturePaymentRequest{PaymentID: payment.ID}); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("second capture: err = %v, want ErrInvalidTransition", err)
+	}
+}
+
+func TestVoidAuthorization(t *testing.T) {
+	cfg := DefaultConfig()
+	tests := []struct {
+		name       string
+		void       func(s *serviceImpl, payment Payment) error
+		wantStatus PaymentStatus
+	}{
+		{
+			name: "void",
+			void: func(s *serviceImpl, payment Payment) error {
+				_, err := s.VoidPayment(context.Background(), VoidPaymentRequest{PaymentID: payment.ID})
+				return err
+			},
+			wantStatus: PaymentStatusVoided,
+		},
+		{
+			name: "close",
+			void: func(s *serviceImpl, payment Payment) error {
+				_, err := s.UpdatePayment(context.Background(), UpdatePaymentRequest{PaymentID: payment.ID, PaymentStatus: PaymentStatusClosed})
+				return err
+			},
+			wantStatus: PaymentStatusClosed,
+		},
+		{
+			name: "expiry",
+			void: func(s *serviceImpl, payment Payment) error {
+				s.expireAuthorizations(context.Background(), payment.AuthorizationExpiresAt.Add(time.Second))
+				return nil
+			},
+			wantStatus: PaymentStatusVoided,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &testAuthorizer{}
+			s, st := newTestService(t, cfg, WithProvider(provider))
+			payment := authorizeTestPayment(t, s)
+
+			// an authorization still within its capture window is kept
+			s.expireAuthorizations(context.Background(), time.Now())
+			if provider.voids != 0 {
+				t.Fatal("authorization voided before it expired")
+			}
+
+			if err := tt.void(s, payment); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.GetPayment(context.Background(), GetPaymentRequest{PaymentID: payment.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus || provider.voids != 1 {
+				t.Errorf("payment is %s after %d voids, want %s after 1", got.Status, provider.voids, tt.wantStatus)
+			}
+			if inQueue(t, st, cfg.Queues.Authorized, payment.ID) {
+				t.Error("voided payment is still in the authorized queue")
+			}
+		})
+	}
+}
+
+func TestManualCaptureRequiresAnAuthorizer(t *testing.T) {
+	s, _ := newTestService(t, DefaultConfig(), WithProvider(&testProvider{}))
+	payment := Payment{
+		ID:          uuid.New(),
+		OrderID:     uuid.New(),
+		Price:       money.Money{Amount: decimal.RequireFromString("19.90"), Currency: "BRL"},
+		CaptureMode: CaptureModeManual,
+	}
+	_, err := s.CreatePayment(context.Background(), CreatePaymentRequest{Payment: payment})
+	assertFieldErrors(t, err, []FieldError{{Field: "payment.CaptureMode", Message: "manual is not supported by the payment provider"}})
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Config holds the settings of the payment service
+type Config struct {
+	Queues   Queues
+	Workers  int
+	Provider ProviderConfig
+	Events   EventsConfig
+	Inbox    InboxConfig
+	Orders   OrdersConfig
+	Limits   LimitsConfig
+	// Expiration sets when stale pending payments expire
+	Expiration ExpirationConfig
+	// Authorization sets how long uncaptured authorizations are kept
+	Authorization AuthorizationConfig
+	// Scheduler drives the expiry of stale pending payments and uncaptured authorizations
+	Scheduler SchedulerConfig
+	Wait      WaitConfig
+}
+
+// Queues holds the names of the lists used by the payment pipeline
+type Queues struct {
+	Pending    string
+	Processing string
+	Paid       string
+	Failed     string
+	// Authorized holds the authorized payments waiting for their capture
+	Authorized string
+	DeadLetter string
+	// Quarantine holds the incoming messages that could not be applied
+	Quarantine string
+}
+
+// ProviderConfig holds the settings of the payment provider
+type ProviderConfig struct {
+	// SuccessRate is the probability, between 0 and 1, of the mock provider approving a payment
+	SuccessRate float64
+	// Latency is added to every payment processed by the provider
+	Latency time.Duration
+	// Timeout bounds every charge, a charge that takes longer fails with the timeout decline reason
+	Timeout time.Duration
+}
+
+// Formats of the published messages
+const (
+	// EventFormatBare publishes the message payload alone, the format used before the envelope existed
+	EventFormatBare = "bare"
+	// EventFormatEnvelope wraps the payload in a messages.Envelope
+	EventFormatEnvelope = "envelope"
+	// EventFormatCloudEvents publishes CloudEvents 1.0 in the structured JSON format
+	EventFormatCloudEvents = "cloudevents"
+)
+
+// EventsConfig holds the settings of the published messages
+type EventsConfig struct {
+	Format string
+	// Source is the CloudEvents source of the published events
+	Source string
+}
+
+// InboxConfig holds the settings of the deduplication of incoming messages
+type InboxConfig struct {
+	// TTL is how long a processed message is remembered
+	TTL time.Duration
+}
+
+// Actions of the order status mapping that do not map to a payment status
+const (
+	// OrderStatusIgnore acknowledges the message without changing any payment
+	OrderStatusIgnore = "ignore"
+	// OrderStatusReject refuses the message, which is quarantined
+	OrderStatusReject = "reject"
+)
+
+// OrdersConfig holds how the messages of the order service are interpreted
+type OrdersConfig struct {
+	// StatusMapping maps each order status to a payment status, OrderStatusIgnore or OrderStatusReject
+	StatusMapping map[string]string
+}
+
+// ValidOrderStatusAction reports whether action can be used in the order status mapping
+func ValidOrderStatusAction(action string) bool {
+	switch action {
+	case string(PaymentStatusPending), string(PaymentStatusPaid), string(PaymentStatusFailed), string(PaymentStatusClosed),
+		OrderStatusIgnore, OrderStatusReject:
+		return true
+	}
+	return false
+}
+
+// LimitsConfig holds the limits of the accepted payments
+type LimitsConfig struct {
+	// MaxAmounts is the largest amount accepted for each currency, currencies without an entry have no limit
+	MaxAmounts map[string]decimal.Decimal
+}
+
+// ExpirationConfig holds the settings of the expiry of pending payments
+type ExpirationConfig struct {
+	// PendingTTL is how long a payment may stay pending before it expires, zero disables the expiry
+	PendingTTL time.Duration
+}
+
+// AuthorizationConfig holds the settings of the payments with manual capture
+type AuthorizationConfig struct {
+	// CaptureWindow is how long an authorization can be captured before it is voided
+	CaptureWindow time.Duration
+}
+
+// SchedulerConfig holds the settings of the background expiry of payments
+type SchedulerConfig struct {
+	// Interval is the time between two checks for expired payments
+	Interval time.Duration
+}
+
+// WaitConfig holds the settings of the requests waiting for a payment to be final
+type WaitConfig struct {
+	// Max caps the wait of a request, longer waits are shortened to it
+	Max time.Duration
+}
+
+// DefaultConfig returns the settings the service used before it was configurable
+func DefaultConfig() Config {
+	return Config{
+		Queues: Queues{
+			Pending:    "payment_pending_queue",
+			Processing: "payments_processing",
+			Paid:       "payment_paid_queue",
+			Failed:     "payment_failed_queue",
+			Authorized: "payment_authorized_queue",
+			DeadLetter: "payments_deadletter",
+			Quarantine: "payments_quarantine",
+		},
+		Workers: 1,
+		Provider: ProviderConfig{
+			SuccessRate: 0.8,
+			Timeout:     30 * time.Second,
+		},
+		Events: EventsConfig{
+			Format: EventFormatBare,
+			Source: "/msvc-payments",
+		},
+		Inbox: InboxConfig{
+			TTL: 24 * time.Hour,
+		},
+		Orders: OrdersConfig{
+			StatusMapping: map[string]string{
+				"Aberto":               string(PaymentStatusPending),
+				"Aguardando Pagamento": string(PaymentStatusPending),
+				"Recebido":             string(PaymentStatusPaid),
+				"Cancelado":            string(PaymentStatusClosed),
+			},
+		},
+		Limits: LimitsConfig{
+			MaxAmounts: map[string]decimal.Decimal{
+				"BRL": decimal.NewFromInt(100000),
+			},
+		},
+		Expiration: ExpirationConfig{
+			PendingTTL: 15 * time.Minute,
+		},
+		Authorization: AuthorizationConfig{
+			CaptureWindow: 7 * 24 * time.Hour,
+		},
+		Scheduler: SchedulerConfig{
+			Interval: 30 * time.Second,
+		},
+		Wait: WaitConfig{
+			Max: 10 * time.Second,
+		},
+	}
+}
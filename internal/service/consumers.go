@@ -2,7 +2,8 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/SOAT1StackGoLang/msvc-payments/pkg/messages"
 	"math"
 	"os"
@@ -22,27 +23,28 @@ type BackgroundService interface {
 	StartProcessingPayments()
 }
 
+// paymentProccess processes payments until ctx is done
 func (s *serviceImpl) paymentProccess(ctx context.Context) error {
 	logger.Info("Initializing payments processing...")
 
-	// Listen for a shutdown signal
-	shutdown := make(chan os.Signal, 1)
-	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
-
 	for {
 		select {
-		case <-shutdown:
+		case <-ctx.Done():
 			// Stop processing payments and return
 			logger.Info("Shutting down payment processing...")
 			return nil
 		default:
 			// Process a payment
 			payment_id, err := s.processPayment(ctx)
-			if err != nil {
+			if err != nil && ctx.Err() == nil {
 				logger.Error("Error while processing payments: ", err.Error())
 				// Retry the operation with exponential backoff
 				for i := 0; i < 3; i++ {
-					time.Sleep(time.Second * time.Duration(math.Pow(2, float64(i))))
+					select {
+					case <-time.After(time.Second * time.Duration(math.Pow(2, float64(i)))):
+					case <-ctx.Done():
+						return nil
+					}
 					payment_id, err = s.processPayment(ctx)
 					if err == nil {
 						break
@@ -50,12 +52,12 @@ func (s *serviceImpl) paymentProccess(ctx context.Context) error {
 				}
 				// If the operation still fails, move the payment to a dead-letter queue
 				if err != nil {
-					err = s.redisClient.LPush(ctx, "payments_deadletter", payment_id)
+					err = s.queue.LPush(ctx, s.cfg.Queues.DeadLetter, payment_id)
 					if err != nil {
 						logger.Error("Error while moving payment to dead-letter queue: ", err.Error())
 					}
 					// remove from the payments processing queue
-					err = s.redisClient.LREM(ctx, "payments_processing", 0, payment_id)
+					err = s.queue.LREM(ctx, s.cfg.Queues.Processing, 0, payment_id)
 					if err != nil {
 						logger.Error("Error while cleaning up payment: ", err.Error())
 					}
@@ -70,8 +72,12 @@ func (s *serviceImpl) processPayment(ctx context.Context) (string, error) {
 	for {
 		// get the payment from the payments pending queue
 
-		payment_id, err := s.redisClient.BLMOVE(ctx, "payment_pending_queue", "payments_processing")
+		payment_id, err := s.queue.BLMOVE(ctx, s.cfg.Queues.Pending, s.cfg.Queues.Processing)
 		if err != nil {
+			// the workers are stopping, not an error
+			if ctx.Err() != nil {
+				return "", err
+			}
 			logger.Error("Error while processing payments: ", err.Error())
 			return "", err
 		}
@@ -82,20 +88,14 @@ func (s *serviceImpl) processPayment(ctx context.Context) (string, error) {
 			logger.Error("Error while parsing payment id: ", err.Error())
 			return "", err
 		}
-		// process the payment
+		// process the payment and store its status
 		payment, err := s.ProcessPayment(ctx, payment_id_valid)
 		if err != nil {
 			logger.Error("Error while processing payment: ", err.Error())
 			return "", err
 		}
-		// update the payment status
-		_, err = s.UpdatePayment(ctx, UpdatePaymentRequest{PaymentID: payment_id_valid, PaymentStatus: payment.Status})
-		if err != nil {
-			logger.Error("Error while updating payment: ", err.Error())
-			return "", err
-		}
 		// cleanup the payment from the payments processing queue
-		err = s.redisClient.LREM(ctx, "payments_processing", 0, payment.ID.String())
+		err = s.queue.LREM(ctx, s.cfg.Queues.Processing, 0, payment.ID.String())
 		if err != nil {
 			logger.Error("Error while cleaning up payment: ", err.Error())
 			return "", err
@@ -105,38 +105,60 @@ func (s *serviceImpl) processPayment(ctx context.Context) (string, error) {
 	}
 }
 
+// StartProcessingPayments starts the configured number of payment processing workers
+// and blocks until all of them stop, which happens on SIGINT or SIGTERM.
 func (s *serviceImpl) StartProcessingPayments() {
+	// Listen for a shutdown signal
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s.ProcessPayments(ctx)
+}
+
+// ProcessPayments runs the configured number of payment processing workers until ctx is done
+func (s *serviceImpl) ProcessPayments(ctx context.Context) {
 	var wg sync.WaitGroup
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-
-		retryInterval := time.Second * 5 // Retry every 5 seconds
-		maxRetries := 3                  // Maximum number of retries
-
-		for i := 0; i < maxRetries; i++ {
-			err := s.paymentProccess(context.Background())
-			if err != nil {
-				logger.Info("Error while processing payments: ", err.Error())
-				logger.Info("Retrying in ", retryInterval.String(), " seconds...")
-
-				// Wait for retryInterval before retrying
-				timer := time.NewTimer(retryInterval)
-				<-timer.C // Directly receive from the timer's channel
-			} else {
-				// If no error, break the loop
-				break
+	workers := s.cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			retryInterval := time.Second * 5 // Retry every 5 seconds
+			maxRetries := 3                  // Maximum number of retries
+
+			for i := 0; i < maxRetries; i++ {
+				err := s.paymentProccess(ctx)
+				if err != nil {
+					logger.Info("Error while processing payments: ", err.Error())
+					logger.Info("Retrying in ", retryInterval.String(), " seconds...")
+
+					// Wait for retryInterval before retrying
+					timer := time.NewTimer(retryInterval)
+					<-timer.C // Directly receive from the timer's channel
+				} else {
+					// If no error, break the loop
+					break
+				}
 			}
-		}
-	}()
+		}()
+	}
 
 	wg.Wait()
 }
 
 func (s *serviceImpl) StartConsumingPaymentsRequests() {
-	ctx := context.Background()
-	sub, err := s.redisClient.Subscribe(ctx, messages.OrderPaymentCreationRequestChannel)
+	s.ConsumePaymentsRequests(context.Background())
+}
+
+// ConsumePaymentsRequests applies the payment creation requests of the order service until ctx is done
+func (s *serviceImpl) ConsumePaymentsRequests(ctx context.Context) {
+	sub, err := s.bus.Subscribe(ctx, messages.OrderPaymentCreationRequestChannel)
 	if err != nil {
 		logger.Error("failed subscribing to payment creation requests")
 		return
@@ -146,47 +168,109 @@ func (s *serviceImpl) StartConsumingPaymentsRequests() {
 		select {
 		case <-ctx.Done():
 			return
-		case msg := <-sub:
-			s.handlePaymentCreationRequest(msg.Payload)
+		case msg, ok := <-sub:
+			if !ok {
+				logger.Error("payment creation requests subscription closed")
+				return
+			}
+			s.handlePaymentCreationRequest(ctx, msg.Payload)
 		}
 	}
 }
 
-func (s *serviceImpl) handlePaymentCreationRequest(payload string) {
+func (s *serviceImpl) handlePaymentCreationRequest(ctx context.Context, payload string) {
+	_, err := s.receivePaymentCreationRequest(ctx, payload)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+}
+
+// receivePaymentCreationRequest decodes, dedupes and applies a creation request.
+// Messages that can never be applied are quarantined and returned, other failures are returned as errors.
+func (s *serviceImpl) receivePaymentCreationRequest(ctx context.Context, payload string) (*QuarantinedMessage, error) {
+	// accept both enveloped messages and the bare format published before the envelope
+	envelope, err := messages.Decode([]byte(payload), messages.TypePaymentCreationRequest)
+	if err != nil {
+		return s.quarantine(ctx, payload, fmt.Errorf("%w: failed decoding payment creation request: %w", ErrInvalidMessage, err))
+	}
+	if envelope.Type != messages.TypePaymentCreationRequest {
+		return s.quarantine(ctx, payload, fmt.Errorf("%w: unexpected message type %q", ErrInvalidMessage, envelope.Type))
+	}
+
+	// acknowledge and skip the messages already processed
+	id := inboxID(envelope, payload)
+	if !s.firstDelivery(ctx, id) {
+		return nil, nil
+	}
+
+	err = s.applyPaymentCreationRequest(ctx, envelope)
+	if err != nil {
+		// a redelivery, or the replay of the quarantined message, is processed again
+		s.forgetDelivery(ctx, id)
+		if errors.Is(err, ErrInvalidMessage) {
+			return s.quarantine(ctx, payload, err)
+		}
+		return nil, err
+	}
+	return nil, nil
+}
+
+// applyPaymentCreationRequest creates the requested payment, or closes it when the order was closed.
+// The order status is resolved through the configured mapping.
+func (s *serviceImpl) applyPaymentCreationRequest(ctx context.Context, envelope messages.Envelope) error {
 	var paymentRequest messages.PaymentCreationRequestMessage
-	err := json.Unmarshal([]byte(payload), &paymentRequest)
+	err := envelope.DecodePayload(&paymentRequest)
 	if err != nil {
-		logger.Error("failed unmarshalling payment creation request")
-		return
+		return fmt.Errorf("%w: failed unmarshalling payment creation request: %w", ErrInvalidMessage, err)
 	}
 
-	pR, err := PaymentFromPaymentCreationRequestMessage(paymentRequest)
+	action, ok := s.cfg.Orders.StatusMapping[paymentRequest.Status]
+	switch {
+	case !ok:
+		return fmt.Errorf("%w: %w %q", ErrInvalidMessage, ErrUnknownOrderStatus, paymentRequest.Status)
+	case action == OrderStatusIgnore:
+		logger.Debug("ignoring order status", paymentRequest.Status, "of payment", paymentRequest.ID)
+		return nil
+	case action == OrderStatusReject:
+		return fmt.Errorf("%w: %w %q", ErrInvalidMessage, ErrRejectedOrderStatus, paymentRequest.Status)
+	}
+
+	pR, err := PaymentFromPaymentCreationRequestMessage(paymentRequest, PaymentStatus(action))
 	if err != nil {
-		logger.Error("failed converting payment creation request")
-		return
+		return fmt.Errorf("%w: failed converting payment creation request: %w", ErrInvalidMessage, err)
 	}
 
 	if pR.Status == PaymentStatusClosed {
-		_, err := s.UpdatePayment(context.Background(), UpdatePaymentRequest{
+		_, err := s.UpdatePayment(ctx, UpdatePaymentRequest{
 			PaymentID:     pR.ID,
 			PaymentStatus: PaymentStatusClosed,
 		})
-		if err != nil {
-			logger.Error("failed updating payment")
+		if errors.Is(err, ErrPaymentNotFound) {
+			// the order was closed before its payment was created, there is nothing to close
+			logger.Debug("closing missing payment", pR.ID.String())
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed updating payment: %w", err)
 		}
-
-		return
+		return nil
 	}
-	_, err = s.CreatePayment(context.Background(), CreatePaymentRequest{Payment: Payment{
+	_, err = s.CreatePayment(ctx, CreatePaymentRequest{Payment: Payment{
 		ID:        pR.ID,
 		CreatedAt: pR.CreatedAt,
 		UpdatedAt: pR.UpdatedAt,
 		Price:     pR.Price,
 		OrderID:   pR.OrderID,
 		Status:    pR.Status,
+		// orders placed at the kiosk are authorized and captured when the kitchen accepts them
+		CaptureMode: pR.CaptureMode,
+		// bare messages have no ID, the correlation starts with the first enveloped event
+		CorrelationID: envelope.CorrelationID,
+		CausationID:   envelope.ID,
 	}})
-	if err != nil {
-		logger.Error("failed creating payment")
-		return
+	if errors.Is(err, ErrValidationFailed) {
+		return fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+	} else if err != nil {
+		return fmt.Errorf("failed creating payment: %w", err)
 	}
+	return nil
 }
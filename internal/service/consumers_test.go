@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/messages"
+	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+	"github.com/google/uuid"
+)
+
+// newTestService returns a service on an empty memory store, along with the store
+func newTestService(t *testing.T, cfg Config, opts ...Option) (*serviceImpl, datastore.RedisStore) {
+	t.Helper()
+	if logger.ErrorLogger == nil {
+		logger.InitializeLoggerWithOptions("error", "logfmt")
+	}
+	st := datastore.NewMemoryStore()
+	t.Cleanup(func() { _ = st.CloseClient() })
+	return NewService(st, st, st, cfg, opts...).(*serviceImpl), st
+}
+
+// creationRequest returns a bare payment creation request of the order status
+func creationRequest(t *testing.T, paymentID uuid.UUID, orderStatus string) string {
+	t.Helper()
+	payload, err := json.Marshal(messages.PaymentCreationRequestMessage{
+		SchemaVersion: 2,
+		ID:            paymentID.String(),
+		CreatedAt:     "2024-01-02T15:04:05Z",
+		UpdatedAt:     "2024-01-02T15:04:05Z",
+		Amount:        1990,
+		Currency:      "BRL",
+		OrderID:       uuid.NewString(),
+		Status:        orderStatus,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(payload)
+}
+
+func TestReceivePaymentCreationRequestOrderStatusActions(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Orders.StatusMapping["Em revisão"] = OrderStatusIgnore
+	cfg.Orders.StatusMapping["Fraude"] = OrderStatusReject
+
+	tests := []struct {
+		name            string
+		orderStatus     string
+		wantQuarantined error
+		wantPayment     bool
+	}{
+		{name: "ignore acknowledges the message", orderStatus: "Em revisão"},
+		{name: "reject quarantines the message", orderStatus: "Fraude", wantQuarantined: ErrRejectedOrderStatus},
+		{name: "unknown statuses are quarantined", orderStatus: "Abrto", wantQuarantined: ErrUnknownOrderStatus},
+		{name: "mapped statuses create the payment", orderStatus: "Aberto", wantPayment: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestService(t, cfg)
+			ctx := context.Background()
+			paymentID := uuid.New()
+
+			quarantined, err := s.receivePaymentCreationRequest(ctx, creationRequest(t, paymentID, tt.orderStatus))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			listed, err := s.ListQuarantinedMessages(ctx, ListQuarantinedMessagesRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantQuarantined == nil {
+				if quarantined != nil || len(listed.Messages) != 0 {
+					t.Fatalf("message quarantined: %+v", quarantined)
+				}
+			} else {
+				if quarantined == nil || len(listed.Messages) != 1 {
+					t.Fatalf("message not quarantined, %d in quarantine", len(listed.Messages))
+				}
+				if !strings.Contains(quarantined.Error, tt.wantQuarantined.Error()) {
+					t.Errorf("quarantine reason = %q, want %q", quarantined.Error, tt.wantQuarantined)
+				}
+			}
+
+			_, _, err = s.loadPayment(ctx, paymentID)
+			if created := err == nil; created != tt.wantPayment {
+				t.Errorf("payment created = %v, want %v (%v)", created, tt.wantPayment, err)
+			}
+			if err != nil && !errors.Is(err, ErrPaymentNotFound) {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestClosingMissingPayment(t *testing.T) {
+	s, _ := newTestService(t, DefaultConfig())
+	ctx := context.Background()
+	paymentID := uuid.New()
+
+	_, err := s.UpdatePayment(ctx, UpdatePaymentRequest{PaymentID: paymentID, PaymentStatus: PaymentStatusClosed})
+	if !errors.Is(err, ErrPaymentNotFound) {
+		t.Fatalf("UpdatePayment err = %v, want ErrPaymentNotFound", err)
+	}
+
+	// the order may be closed before its payment was created
+	quarantined, err := s.receivePaymentCreationRequest(ctx, creationRequest(t, paymentID, "Cancelado"))
+	if err != nil || quarantined != nil {
+		t.Fatalf("closing a missing payment from a message: %+v, %v", quarantined, err)
+	}
+}
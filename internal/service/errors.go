@@ -0,0 +1,28 @@
+package service
+
+import "errors"
+
+var (
+	// ErrPaymentNotFound is returned when the requested payment does not exist
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrPaymentAlreadyExists is returned when a payment is created with the ID of an existing one
+	ErrPaymentAlreadyExists = errors.New("payment already exists")
+	// ErrOrderHasActivePayment is returned when a payment is created for an order whose payment is still pending or paid
+	ErrOrderHasActivePayment = errors.New("order already has an active payment")
+	// ErrInvalidTransition is returned when a payment can't move from its status to the requested one
+	ErrInvalidTransition = errors.New("invalid payment status transition")
+	// ErrCaptureNotSupported is returned for payments with manual capture when the provider can't authorize them
+	ErrCaptureNotSupported = errors.New("the payment provider does not support manual capture")
+	// ErrValidationFailed is returned when a request has invalid fields
+	ErrValidationFailed = errors.New("validation failed")
+	// ErrVersionConflict is returned when a payment changed since the version the caller expected
+	ErrVersionConflict = errors.New("payment version conflict")
+	// ErrUnknownOrderStatus is returned for order statuses missing from the status mapping
+	ErrUnknownOrderStatus = errors.New("unknown order status")
+	// ErrRejectedOrderStatus is returned for order statuses mapped to OrderStatusReject
+	ErrRejectedOrderStatus = errors.New("rejected order status")
+	// ErrInvalidMessage marks incoming messages that can never be applied as they are, they are quarantined
+	ErrInvalidMessage = errors.New("invalid message")
+	// ErrQuarantinedMessageNotFound is returned when the requested quarantined message does not exist
+	ErrQuarantinedMessageNotFound = errors.New("quarantined message not found")
+)
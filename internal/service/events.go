@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/messages"
+)
+
+// publishStatusChanged notifies the payment status on PaymentStatusResponseChannel in the configured format
+func (s *serviceImpl) publishStatusChanged(ctx context.Context, payment Payment) error {
+	body, err := s.encodeEvent(payment, messages.TypePaymentStatusChanged, messages.PaymentEventType(string(payment.Status)),
+		messages.PaymentStatusChangedSchemaVersion, PaymentStatusChangedMessageFromPayment(payment))
+	if err != nil {
+		return err
+	}
+	return s.bus.Publish(ctx, messages.PaymentStatusResponseChannel, body)
+}
+
+// encodeEvent encodes the payload of an event about the payment in the configured format,
+// msgType is the envelope type and ceType the CloudEvents type of the event.
+// Enveloped events carry on the correlation of the message that created the payment.
+func (s *serviceImpl) encodeEvent(payment Payment, msgType, ceType string, version int, payload any) ([]byte, error) {
+	opts := []messages.EnvelopeOption{
+		messages.WithCorrelationID(payment.CorrelationID),
+		messages.WithCausationID(payment.CausationID),
+	}
+	switch s.cfg.Events.Format {
+	case EventFormatEnvelope:
+		return messages.Encode(msgType, version, payload, opts...)
+	case EventFormatCloudEvents:
+		return messages.EncodeCloudEvent(s.cfg.Events.Source, ceType, payment.ID.String(), version, payload, opts...)
+	default:
+		return json.Marshal(payload)
+	}
+}
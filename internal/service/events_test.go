@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/messages"
+)
+
+func TestPublishStatusChangedFormats(t *testing.T) {
+	tests := []struct {
+		format   string
+		wantType string
+		wantBare bool
+	}{
+		{format: EventFormatBare, wantType: messages.TypePaymentStatusChanged, wantBare: true},
+		{format: EventFormatEnvelope, wantType: messages.TypePaymentStatusChanged},
+		{format: EventFormatCloudEvents, wantType: "com.soat.payment.pending"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Events.Format, cfg.Events.Source = tt.format, "/payments"
+			s, st := newTestService(t, cfg, WithProvider(&testProvider{}))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			published, err := st.Subscribe(ctx, messages.PaymentStatusResponseChannel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			payment := createTestPayment(t, s)
+			payment.Status = PaymentStatusPending
+			if err := s.publishStatusChanged(ctx, payment); err != nil {
+				t.Fatal(err)
+			}
+
+			var msg *datastore.Message
+			select {
+			case msg = <-published:
+			case <-time.After(time.Second):
+				t.Fatal("no status change published")
+			}
+			e, err := messages.Decode([]byte(msg.Payload), messages.TypePaymentStatusChanged)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.Type != tt.wantType || e.Bare != tt.wantBare {
+				t.Errorf("published %s message, bare %v, want %s, bare %v", e.Type, e.Bare, tt.wantType, tt.wantBare)
+			}
+			var changed messages.PaymentStatusChangedMessage
+			if err := e.DecodePayload(&changed); err != nil {
+				t.Fatal(err)
+			}
+			if changed.ID != payment.ID.String() || changed.Status != string(PaymentStatusPending) {
+				t.Errorf("published %+v, want payment %s pending", changed, payment.ID)
+			}
+		})
+	}
+}
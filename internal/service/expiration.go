@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+	"github.com/google/uuid"
+)
+
+// errNotExpired is returned by expirePayment when the payment can't expire yet
+var errNotExpired = errors.New("payment has not expired")
+
+// pendingExpiry returns when a payment pending since from expires, nil when the expiry is disabled
+func (s *serviceImpl) pendingExpiry(from time.Time) *time.Time {
+	if s.cfg.Expiration.PendingTTL <= 0 {
+		return nil
+	}
+	expiresAt := from.Add(s.cfg.Expiration.PendingTTL)
+	return &expiresAt
+}
+
+// expired reports whether the payment is pending past its expiry
+func (p Payment) expired(now time.Time) bool {
+	return p.Status == PaymentStatusPending && p.ExpiresAt != nil && !now.Before(*p.ExpiresAt)
+}
+
+// expirePendingPayments expires the payments left pending past their expiry, waiting in the pending
+// or dead-letter queue. The payments being charged are left to the workers, which expire them as well.
+func (s *serviceImpl) expirePendingPayments(ctx context.Context, now time.Time) {
+	for _, queue := range []string{s.cfg.Queues.Pending, s.cfg.Queues.DeadLetter} {
+		ids, err := s.queue.LRange(ctx, queue, 0, -1)
+		if err != nil {
+			logger.Error("failed listing payments of", queue, err.Error())
+			continue
+		}
+
+		for _, id := range ids {
+			paymentID, err := uuid.Parse(id)
+			if err != nil {
+				continue
+			}
+			payment, _, err := s.loadPayment(ctx, paymentID)
+			if err != nil {
+				logger.Error("failed reading pending payment", id, err.Error())
+				continue
+			}
+			if !payment.expired(now) {
+				continue
+			}
+
+			logger.Info("expiring pending payment", id)
+			if _, err := s.expirePayment(ctx, paymentID, now); err != nil && !errors.Is(err, errNotExpired) {
+				logger.Error("failed expiring payment", id, err.Error())
+			}
+		}
+	}
+}
+
+// expirePayment moves a payment pending past its expiry to expired, removes it from the queues
+// and notifies the status
+func (s *serviceImpl) expirePayment(ctx context.Context, paymentID uuid.UUID, now time.Time) (Payment, error) {
+	payment, err := s.modifyPayment(ctx, paymentID, nil, func(p *Payment) error {
+		// it may have been charged or retried meanwhile
+		if !p.expired(now) {
+			return errNotExpired
+		}
+		p.Status = PaymentStatusExpired
+		return nil
+	})
+	if err != nil {
+		return Payment{}, err
+	}
+
+	// Removing from queues
+	_ = s.queue.LREM(ctx, s.cfg.Queues.Pending, 0, paymentID.String())
+	_ = s.queue.LREM(ctx, s.cfg.Queues.Processing, 0, paymentID.String())
+	_ = s.queue.LREM(ctx, s.cfg.Queues.DeadLetter, 0, paymentID.String())
+
+	err = s.publishStatusChanged(ctx, payment)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+	return payment, nil
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/messages"
+)
+
+func TestExpirePendingPayments(t *testing.T) {
+	cfg := DefaultConfig()
+	s, st := newTestService(t, cfg, WithProvider(&testProvider{}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	published, err := st.Subscribe(ctx, messages.PaymentStatusResponseChannel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := createTestPayment(t, s)
+	payment, _, err := s.loadPayment(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.ExpiresAt == nil || payment.ExpiresAt.Sub(payment.CreatedAt) != cfg.Expiration.PendingTTL {
+		t.Fatalf("payment created at %v expires at %v, want after %s", payment.CreatedAt, payment.ExpiresAt, cfg.Expiration.PendingTTL)
+	}
+
+	s.expirePendingPayments(ctx, payment.ExpiresAt.Add(-time.Second))
+	if stored, _, _ := s.loadPayment(ctx, payment.ID); stored.Status != PaymentStatusPending {
+		t.Fatalf("payment is %s before its expiry, want pending", stored.Status)
+	}
+
+	s.expirePendingPayments(ctx, payment.ExpiresAt.Add(time.Second))
+	stored, _, err := s.loadPayment(ctx, payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != PaymentStatusExpired {
+		t.Errorf("payment is %s after its expiry, want expired", stored.Status)
+	}
+	if inQueue(t, st, cfg.Queues.Pending, payment.ID) {
+		t.Error("expired payment is still in the pending queue")
+	}
+	select {
+	case msg := <-published:
+		var changed messages.PaymentStatusChangedMessage
+		e, err := messages.Decode([]byte(msg.Payload), messages.TypePaymentStatusChanged)
+		if err == nil {
+			err = e.DecodePayload(&changed)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed.ID != payment.ID.String() || changed.Status != string(PaymentStatusExpired) {
+			t.Errorf("published %+v, want payment %s expired", changed, payment.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no status change published")
+	}
+}
+
+func TestChargedPaymentsDoNotExpire(t *testing.T) {
+	provider := &blockingProvider{
+		testProvider: testProvider{result: ChargeResult{Status: PaymentStatusPaid}},
+		started:      make(chan struct{}, 1),
+		release:      make(chan struct{}),
+	}
+	s, _ := newTestService(t, DefaultConfig(), WithProvider(provider))
+	ctx := context.Background()
+	payment := createTestPayment(t, s)
+
+	processed := make(chan error, 1)
+	go func() {
+		_, err := s.ProcessPayment(ctx, payment.ID)
+		processed <- err
+	}()
+	<-provider.started
+
+	// the scheduler runs while the provider charges the payment
+	s.expirePendingPayments(ctx, time.Now().Add(time.Hour))
+	close(provider.release)
+	if err := <-processed; err != nil {
+		t.Fatal(err)
+	}
+	stored, _, err := s.loadPayment(ctx, payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != PaymentStatusPaid {
+		t.Errorf("payment is %s, want paid", stored.Status)
+	}
+}
+
+func TestWorkersExpireInsteadOfCharging(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Expiration.PendingTTL = time.Millisecond
+	provider := &testProvider{result: ChargeResult{Status: PaymentStatusPaid}}
+	s, _ := newTestService(t, cfg, WithProvider(provider))
+	payment := createTestPayment(t, s)
+	time.Sleep(5 * time.Millisecond)
+
+	processed, err := s.ProcessPayment(context.Background(), payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed.Status != PaymentStatusExpired || provider.charges != 0 {
+		t.Errorf("payment is %s after %d charges, want expired without charges", processed.Status, provider.charges)
+	}
+}
+
+func TestPendingExpiryCanBeDisabled(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Expiration.PendingTTL = 0
+	s, _ := newTestService(t, cfg, WithProvider(&testProvider{}))
+	created := createTestPayment(t, s)
+
+	payment, _, err := s.loadPayment(context.Background(), created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.ExpiresAt != nil {
+		t.Errorf("payment expires at %v, want no expiry", payment.ExpiresAt)
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/messages"
+	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+)
+
+// duplicateMessages counts the incoming messages skipped because they were already processed,
+// it is served on /debug/vars with the other payments_ variables
+var duplicateMessages = expvar.NewInt("payments_inbox_duplicates")
+
+// inboxID identifies an incoming message by its envelope ID,
+// bare messages have none and are identified by the SHA-256 of their payload
+func inboxID(envelope messages.Envelope, payload string) string {
+	if !envelope.Bare && envelope.ID != "" {
+		return envelope.Type + ":" + envelope.ID
+	}
+	sum := sha256.Sum256([]byte(payload))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// firstDelivery records the message in the inbox and reports whether it was seen for the first time.
+// Without an inbox, or when it fails, every message is processed.
+func (s *serviceImpl) firstDelivery(ctx context.Context, id string) bool {
+	if s.inbox == nil {
+		return true
+	}
+	fresh, err := s.inbox.MarkProcessed(ctx, id, s.cfg.Inbox.TTL)
+	if err != nil {
+		logger.Error(err.Error())
+		return true
+	}
+	if !fresh {
+		duplicateMessages.Add(1)
+		logger.Info("skipping duplicate message", id)
+	}
+	return fresh
+}
+
+// forgetDelivery removes the message from the inbox so a redelivery is processed again
+func (s *serviceImpl) forgetDelivery(ctx context.Context, id string) {
+	if s.inbox == nil {
+		return
+	}
+	if err := s.inbox.Forget(ctx, id); err != nil {
+		logger.Error(err.Error())
+	}
+}
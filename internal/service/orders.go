@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore"
+	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+	"github.com/google/uuid"
+)
+
+// orderKeyPrefix keeps the order index apart from the payment records, which are stored under their UUID.
+// The index of an order holds the ID of its latest payment.
+const orderKeyPrefix = "order:"
+
+// ActivePaymentError is returned when a payment is created for an order that already has an active one
+type ActivePaymentError struct {
+	OrderID   uuid.UUID
+	PaymentID uuid.UUID
+	Status    PaymentStatus
+}
+
+func (e *ActivePaymentError) Error() string {
+	return fmt.Sprintf("%s: order %s has payment %s in status %s", ErrOrderHasActivePayment, e.OrderID, e.PaymentID, e.Status)
+}
+
+// Is makes errors.Is match ErrOrderHasActivePayment
+func (e *ActivePaymentError) Is(target error) bool {
+	return target == ErrOrderHasActivePayment
+}
+
+// activePayment reports whether a payment of the given status blocks new payments for its order,
+// only failed, expired, voided and closed payments can be attempted again. Voided payments released
+// their authorization, while capturing and voiding ones still hold it.
+func activePayment(status PaymentStatus) bool {
+	switch status {
+	case PaymentStatusFailed, PaymentStatusExpired, PaymentStatusVoided, PaymentStatusClosed:
+		return false
+	}
+	return true
+}
+
+func orderIndexKey(orderID uuid.UUID) string {
+	return orderKeyPrefix + orderID.String()
+}
+
+// orderExpectations reads the order index before a payment of the order is created. Unless the latest
+// payment of the order is still active, the creation may move the index to the new payment provided
+// neither the index nor that payment changed meanwhile, which the returned expectations guard.
+func (s *serviceImpl) orderExpectations(ctx context.Context, payment Payment) (map[string]string, error) {
+	key := orderIndexKey(payment.OrderID)
+	latest, err := s.payments.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	expect := map[string]string{key: latest}
+	if latest == "" || latest == payment.ID.String() {
+		return expect, nil
+	}
+
+	latestID, err := uuid.Parse(latest)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading order index %s: %w", key, err)
+	}
+	existing, raw, err := s.loadPayment(ctx, latestID)
+	switch {
+	case errors.Is(err, ErrPaymentNotFound):
+	case err != nil:
+		return nil, err
+	case activePayment(existing.Status):
+		return nil, &ActivePaymentError{OrderID: payment.OrderID, PaymentID: existing.ID, Status: existing.Status}
+	}
+	// a retry of the latest payment changes its record and fails the creation
+	expect[latestID.String()] = raw
+	return expect, nil
+}
+
+// claimOrder points the order index to a payment being retried, unless another payment of the order is active.
+// The retried payment is pending already, so a creation racing with the claim finds it active.
+func (s *serviceImpl) claimOrder(ctx context.Context, payment Payment) error {
+	key := orderIndexKey(payment.OrderID)
+	for attempt := 0; attempt < maxWriteAttempts; attempt++ {
+		latest, err := s.payments.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if latest == payment.ID.String() {
+			return nil
+		}
+		if latestID, err := uuid.Parse(latest); err == nil {
+			existing, _, err := s.loadPayment(ctx, latestID)
+			switch {
+			case errors.Is(err, ErrPaymentNotFound):
+			case err != nil:
+				return err
+			case activePayment(existing.Status):
+				return &ActivePaymentError{OrderID: payment.OrderID, PaymentID: existing.ID, Status: existing.Status}
+			}
+		}
+
+		swapped, err := s.payments.CompareAndSwap(ctx, key, latest, payment.ID.String())
+		if err != nil {
+			return err
+		}
+		if swapped {
+			return nil
+		}
+		logger.Debug("order", payment.OrderID.String(), "claimed concurrently, retrying")
+	}
+	return ErrVersionConflict
+}
+
+// activePaymentError converts the rejection of a repository enforcing a single active payment per order
+func activePaymentError(err error, orderID uuid.UUID) error {
+	if errors.Is(err, datastore.ErrActiveOrderPayment) {
+		return fmt.Errorf("%w: order %s has another active payment", ErrOrderHasActivePayment, orderID)
+	}
+	return err
+}
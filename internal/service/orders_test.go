@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestOneActivePaymentPerOrder(t *testing.T) {
+	provider := &testProvider{result: ChargeResult{Status: PaymentStatusFailed, DeclineReason: DeclineReasonInsufficientFunds}}
+	s, _ := newTestService(t, DefaultConfig(), WithProvider(provider))
+	ctx := context.Background()
+	first := createTestPayment(t, s)
+
+	// createForOrder creates another payment of the order of the first one
+	createForOrder := func() (Payment, error) {
+		payment := first
+		payment.ID = uuid.New()
+		_, err := s.CreatePayment(ctx, CreatePaymentRequest{Payment: payment})
+		return payment, err
+	}
+
+	_, err := createForOrder()
+	var activeErr *ActivePaymentError
+	if !errors.As(err, &activeErr) || activeErr.PaymentID != first.ID || activeErr.Status != PaymentStatusPending {
+		t.Fatalf("second payment of a pending order: err = %v, want the first payment active", err)
+	}
+
+	// a failed payment can be attempted again under a new ID
+	if _, err := s.ProcessPayment(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	second, err := createForOrder()
+	if err != nil {
+		t.Fatalf("payment of an order whose payment failed: %v", err)
+	}
+	if _, err := s.CreatePaymentAttempt(ctx, CreatePaymentAttemptRequest{PaymentID: first.ID}); !errors.Is(err, ErrOrderHasActivePayment) {
+		t.Errorf("retry of a replaced payment: err = %v, want ErrOrderHasActivePayment", err)
+	}
+
+	// so can a closed one
+	if _, err := s.UpdatePayment(ctx, UpdatePaymentRequest{PaymentID: second.ID, PaymentStatus: PaymentStatusClosed}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := createForOrder(); err != nil {
+		t.Errorf("payment of an order whose payment closed: %v", err)
+	}
+}
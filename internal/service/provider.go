@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/money"
+	"github.com/google/uuid"
+)
+
+// Provider charges the payments
+type Provider interface {
+	// Charge returns the outcome of the charge. An error is final, it is not retried: the payment fails
+	// with the timeout reason when the call timed out and provider_error otherwise. Only a charge cut short
+	// by the service stopping leaves the payment pending, to be charged again.
+	Charge(ctx context.Context, payment Payment) (ChargeResult, error)
+}
+
+// Authorizer is implemented by the providers that can hold the funds of a payment and charge them later,
+// it is required by the payments with manual capture
+type Authorizer interface {
+	// Authorize holds the price of the payment, the result status is authorized or failed.
+	// An error fails the payment, as for Charge.
+	Authorize(ctx context.Context, payment Payment) (ChargeResult, error)
+	// Capture charges amount, at most the authorized price, and releases the rest
+	Capture(ctx context.Context, payment Payment, amount money.Money) error
+	// Void releases the funds held by the authorization
+	Void(ctx context.Context, payment Payment) error
+}
+
+// ChargeResult is the outcome of a charge at the provider
+type ChargeResult struct {
+	// Status is paid or failed, authorized or failed for an authorization
+	Status PaymentStatus
+	// Reference identifies the charge at the provider
+	Reference string
+	// DeclineCode is the code the provider gave for a failed charge
+	DeclineCode string
+	// DeclineReason is the normalized reason of a failed charge, provider_error when the provider gave none
+	DeclineReason DeclineReason
+}
+
+// DeclineReason is the normalized reason a charge failed, the same for every provider
+type DeclineReason string
+
+const (
+	DeclineReasonInsufficientFunds DeclineReason = "insufficient_funds"
+	DeclineReasonExpired           DeclineReason = "expired"
+	DeclineReasonFraudSuspected    DeclineReason = "fraud_suspected"
+	// DeclineReasonTimeout is used when the provider did not answer within the configured timeout
+	DeclineReasonTimeout DeclineReason = "timeout"
+	// DeclineReasonProviderError is used when the provider failed or gave no reason
+	DeclineReasonProviderError DeclineReason = "provider_error"
+)
+
+// mockDeclines are the card declines of the mock provider with their ISO 8583 response codes
+var mockDeclines = []struct {
+	code   string
+	reason DeclineReason
+}{
+	{"51", DeclineReasonInsufficientFunds},
+	{"54", DeclineReasonExpired},
+	{"59", DeclineReasonFraudSuspected},
+}
+
+// declineReasonOf returns the reason of a charge that failed with err, a timeout or a provider error
+func declineReasonOf(err error) DeclineReason {
+	var timeout interface{ Timeout() bool }
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &timeout) && timeout.Timeout()) {
+		return DeclineReasonTimeout
+	}
+	return DeclineReasonProviderError
+}
+
+// mockProvider approves payments at random, weighted by the configured success rate
+type mockProvider struct {
+	cfg ProviderConfig
+}
+
+// NewMockProvider creates the provider used when no other is given, it waits cfg.Latency
+// and approves payments with probability cfg.SuccessRate, the others get a random card decline
+func NewMockProvider(cfg ProviderConfig) Provider {
+	return mockProvider{cfg: cfg}
+}
+
+func (p mockProvider) Charge(ctx context.Context, payment Payment) (ChargeResult, error) {
+	if p.cfg.Latency > 0 {
+		select {
+		case <-time.After(p.cfg.Latency):
+		case <-ctx.Done():
+			return ChargeResult{}, ctx.Err()
+		}
+	}
+	result := ChargeResult{Status: MockPaymentProcess(p.cfg.SuccessRate), Reference: "mock_" + uuid.NewString()}
+	if result.Status == PaymentStatusFailed {
+		decline := mockDeclines[rand.Intn(len(mockDeclines))]
+		result.DeclineCode = decline.code
+		result.DeclineReason = decline.reason
+	}
+	return result, nil
+}
+
+// Authorize approves the authorizations like the charges
+func (p mockProvider) Authorize(ctx context.Context, payment Payment) (ChargeResult, error) {
+	result, err := p.Charge(ctx, payment)
+	if result.Status == PaymentStatusPaid {
+		result.Status = PaymentStatusAuthorized
+	}
+	return result, err
+}
+
+func (p mockProvider) Capture(ctx context.Context, payment Payment, amount money.Money) error {
+	return nil
+}
+
+func (p mockProvider) Void(ctx context.Context, payment Payment) error {
+	return nil
+}
+
+// Option customizes the service created by NewService
+type Option func(*serviceImpl)
+
+// WithProvider replaces the mock provider
+func WithProvider(provider Provider) Option {
+	return func(s *serviceImpl) { s.provider = provider }
+}
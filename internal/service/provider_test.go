@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/messages"
+)
+
+// slowProvider answers once the charge is cancelled, like a provider that never answers
+type slowProvider struct{}
+
+func (slowProvider) Charge(ctx context.Context, payment Payment) (ChargeResult, error) {
+	<-ctx.Done()
+	return ChargeResult{}, fmt.Errorf("calling the provider: %w", ctx.Err())
+}
+
+func TestDeclineReasons(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   Provider
+		wantReason DeclineReason
+	}{
+		{
+			name:       "declined",
+			provider:   &testProvider{result: ChargeResult{Status: PaymentStatusFailed, DeclineCode: "59", DeclineReason: DeclineReasonFraudSuspected}},
+			wantReason: DeclineReasonFraudSuspected,
+		},
+		{
+			name:       "declined without a reason",
+			provider:   &testProvider{result: ChargeResult{Status: PaymentStatusFailed}},
+			wantReason: DeclineReasonProviderError,
+		},
+		{
+			name:       "provider error",
+			provider:   &testProvider{err: errors.New("connection refused")},
+			wantReason: DeclineReasonProviderError,
+		},
+		{name: "timeout", provider: slowProvider{}, wantReason: DeclineReasonTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Provider.Timeout = 20 * time.Millisecond
+			s, st := newTestService(t, cfg, WithProvider(tt.provider))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			published, err := st.Subscribe(ctx, messages.PaymentStatusResponseChannel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			created := createTestPayment(t, s)
+			if _, err := s.ProcessPayment(ctx, created.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := s.GetPayment(ctx, GetPaymentRequest{PaymentID: created.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != PaymentStatusFailed || got.PaymentError != string(tt.wantReason) || got.Payment.DeclineReason != tt.wantReason {
+				t.Errorf("payment is %s (%q), want failed (%q)", got.Status, got.PaymentError, tt.wantReason)
+			}
+			if len(got.Payment.Attempts) != 1 || got.Payment.Attempts[0].DeclineReason != tt.wantReason {
+				t.Errorf("attempts = %+v, want one declined with %q", got.Payment.Attempts, tt.wantReason)
+			}
+
+			select {
+			case msg := <-published:
+				var changed messages.PaymentStatusChangedMessage
+				e, err := messages.Decode([]byte(msg.Payload), messages.TypePaymentStatusChanged)
+				if err == nil {
+					err = e.DecodePayload(&changed)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if changed.DeclineReason != string(tt.wantReason) {
+					t.Errorf("published decline reason %q, want %q", changed.DeclineReason, tt.wantReason)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no status change published")
+			}
+
+			// the reason is cleared when the payment is retried
+			if _, err := s.CreatePaymentAttempt(ctx, CreatePaymentAttemptRequest{PaymentID: created.ID}); err != nil {
+				t.Fatal(err)
+			}
+			got, err = s.GetPayment(ctx, GetPaymentRequest{PaymentID: created.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.PaymentError != "" {
+				t.Errorf("retried payment has the error %q", got.PaymentError)
+			}
+		})
+	}
+}
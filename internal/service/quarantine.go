@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+	"github.com/google/uuid"
+)
+
+// QuarantinedMessage is an incoming message that could not be applied, kept for inspection
+type QuarantinedMessage struct {
+	ID         uuid.UUID `json:"id"`
+	ReceivedAt time.Time `json:"received_at"`
+	Error      string    `json:"error"`
+	// Payload is the raw message as it was received
+	Payload string `json:"payload"`
+}
+
+type ListQuarantinedMessagesRequest struct {
+}
+
+type ListQuarantinedMessagesResponse struct {
+	Messages []QuarantinedMessage `json:"messages"`
+}
+
+type ReplayQuarantinedMessageRequest struct {
+	ID uuid.UUID `json:"id"`
+	// Payload replaces the quarantined payload when set, to replay a fixed message
+	Payload *string `json:"payload,omitempty"`
+}
+
+type ReplayQuarantinedMessageResponse struct {
+	ID uuid.UUID `json:"id"`
+	// Quarantined is set when the replayed message could not be applied again and went back to the quarantine
+	Quarantined *QuarantinedMessage `json:"quarantined,omitempty"`
+}
+
+type DiscardQuarantinedMessageRequest struct {
+	ID uuid.UUID `json:"id"`
+}
+
+type DiscardQuarantinedMessageResponse struct {
+	ID uuid.UUID `json:"id"`
+}
+
+// quarantine stores the message with the reason it could not be applied in the quarantine list
+func (s *serviceImpl) quarantine(ctx context.Context, payload string, reason error) (*QuarantinedMessage, error) {
+	logger.Error("quarantining message:", reason.Error())
+
+	msg := QuarantinedMessage{
+		ID:         uuid.New(),
+		ReceivedAt: time.Now().UTC(),
+		Error:      reason.Error(),
+		Payload:    payload,
+	}
+	entry, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	err = s.queue.LPush(ctx, s.cfg.Queues.Quarantine, entry)
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// ListQuarantinedMessages returns the quarantined messages, newest first
+func (s *serviceImpl) ListQuarantinedMessages(ctx context.Context, request ListQuarantinedMessagesRequest) (ListQuarantinedMessagesResponse, error) {
+	entries, err := s.queue.LRange(ctx, s.cfg.Queues.Quarantine, 0, -1)
+	if err != nil {
+		return ListQuarantinedMessagesResponse{}, err
+	}
+
+	messages := make([]QuarantinedMessage, 0, len(entries))
+	for _, entry := range entries {
+		var msg QuarantinedMessage
+		if err := json.Unmarshal([]byte(entry), &msg); err != nil {
+			logger.Error("skipping unreadable quarantine entry:", err.Error())
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return ListQuarantinedMessagesResponse{Messages: messages}, nil
+}
+
+// ReplayQuarantinedMessage processes the message again, with its original payload or with the
+// fixed one of the request. The message leaves the quarantine once it was applied or quarantined
+// again, and stays there when the replay fails
+func (s *serviceImpl) ReplayQuarantinedMessage(ctx context.Context, request ReplayQuarantinedMessageRequest) (ReplayQuarantinedMessageResponse, error) {
+	if err := s.validateQuarantinedMessageID(request.ID); err != nil {
+		return ReplayQuarantinedMessageResponse{}, err
+	}
+	msg, entry, err := s.findQuarantinedMessage(ctx, request.ID)
+	if err != nil {
+		return ReplayQuarantinedMessageResponse{}, err
+	}
+
+	payload := msg.Payload
+	if request.Payload != nil {
+		payload = *request.Payload
+	}
+	quarantined, err := s.receivePaymentCreationRequest(ctx, payload)
+	if err != nil {
+		return ReplayQuarantinedMessageResponse{}, err
+	}
+	err = s.queue.LREM(ctx, s.cfg.Queues.Quarantine, 1, entry)
+	if err != nil {
+		return ReplayQuarantinedMessageResponse{}, err
+	}
+	return ReplayQuarantinedMessageResponse{ID: request.ID, Quarantined: quarantined}, nil
+}
+
+// DiscardQuarantinedMessage deletes the message from the quarantine
+func (s *serviceImpl) DiscardQuarantinedMessage(ctx context.Context, request DiscardQuarantinedMessageRequest) (DiscardQuarantinedMessageResponse, error) {
+	if err := s.validateQuarantinedMessageID(request.ID); err != nil {
+		return DiscardQuarantinedMessageResponse{}, err
+	}
+	_, err := s.takeQuarantinedMessage(ctx, request.ID)
+	if err != nil {
+		return DiscardQuarantinedMessageResponse{}, err
+	}
+	return DiscardQuarantinedMessageResponse{ID: request.ID}, nil
+}
+
+// takeQuarantinedMessage finds the message by its ID and removes it from the quarantine list
+func (s *serviceImpl) takeQuarantinedMessage(ctx context.Context, id uuid.UUID) (QuarantinedMessage, error) {
+	msg, entry, err := s.findQuarantinedMessage(ctx, id)
+	if err != nil {
+		return QuarantinedMessage{}, err
+	}
+	err = s.queue.LREM(ctx, s.cfg.Queues.Quarantine, 1, entry)
+	if err != nil {
+		return QuarantinedMessage{}, err
+	}
+	return msg, nil
+}
+
+// findQuarantinedMessage finds the message by its ID, along with its raw entry in the quarantine list
+func (s *serviceImpl) findQuarantinedMessage(ctx context.Context, id uuid.UUID) (QuarantinedMessage, string, error) {
+	entries, err := s.queue.LRange(ctx, s.cfg.Queues.Quarantine, 0, -1)
+	if err != nil {
+		return QuarantinedMessage{}, "", err
+	}
+
+	for _, entry := range entries {
+		var msg QuarantinedMessage
+		if err := json.Unmarshal([]byte(entry), &msg); err != nil || msg.ID != id {
+			continue
+		}
+		return msg, entry, nil
+	}
+	return QuarantinedMessage{}, "", fmt.Errorf("%w: %s", ErrQuarantinedMessageNotFound, id)
+}
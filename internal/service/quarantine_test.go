@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/messages"
+	"github.com/google/uuid"
+)
+
+func TestReplayQuarantinedMessage(t *testing.T) {
+	s, _ := newTestService(t, DefaultConfig(), WithProvider(&testProvider{}))
+	ctx := context.Background()
+	active := createTestPayment(t, s)
+
+	// a creation request for the order of the active payment, quarantined for its typo
+	request := messages.PaymentCreationRequestMessage{
+		SchemaVersion: 2,
+		ID:            uuid.NewString(),
+		CreatedAt:     "2024-01-02T15:04:05Z",
+		UpdatedAt:     "2024-01-02T15:04:05Z",
+		Amount:        1990,
+		Currency:      "BRL",
+		OrderID:       active.OrderID.String(),
+		Status:        "Abrto",
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quarantined, err := s.receivePaymentCreationRequest(ctx, string(payload))
+	if err != nil || quarantined == nil {
+		t.Fatalf("message not quarantined: %+v, %v", quarantined, err)
+	}
+
+	request.Status = "Aberto"
+	fixed, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixedPayload := string(fixed)
+	_, err = s.ReplayQuarantinedMessage(ctx, ReplayQuarantinedMessageRequest{ID: quarantined.ID, Payload: &fixedPayload})
+	if !errors.Is(err, ErrOrderHasActivePayment) {
+		t.Fatalf("replay err = %v, want ErrOrderHasActivePayment", err)
+	}
+	assertQuarantined(t, s, quarantined.ID, true)
+
+	// once the active payment is closed, the replay applies the message and removes it
+	if _, err := s.UpdatePayment(ctx, UpdatePaymentRequest{PaymentID: active.ID, PaymentStatus: PaymentStatusClosed}); err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := s.ReplayQuarantinedMessage(ctx, ReplayQuarantinedMessageRequest{ID: quarantined.ID, Payload: &fixedPayload})
+	if err != nil || replayed.Quarantined != nil {
+		t.Fatalf("replay: %+v, %v", replayed, err)
+	}
+	assertQuarantined(t, s, quarantined.ID, false)
+	if _, _, err := s.loadPayment(ctx, uuid.MustParse(request.ID)); err != nil {
+		t.Errorf("replayed payment: %v", err)
+	}
+}
+
+// assertQuarantined checks whether the message is listed in the quarantine
+func assertQuarantined(t *testing.T, s *serviceImpl, id uuid.UUID, want bool) {
+	t.Helper()
+	listed, err := s.ListQuarantinedMessages(context.Background(), ListQuarantinedMessagesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, msg := range listed.Messages {
+		found = found || msg.ID == id
+	}
+	if found != want {
+		t.Errorf("message %s listed = %v, want %v", id, found, want)
+	}
+}
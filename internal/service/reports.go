@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/money"
+)
+
+type GetPaymentsReportRequest struct {
+}
+
+type GetPaymentsReportResponse struct {
+	Currencies []CurrencyReport `json:"currencies"`
+}
+
+// CurrencyReport sums the payments of one currency, amounts of different currencies are never added together.
+// Count counts the payments of every status, while Total only sums the amounts charged: the price of the
+// paid payments and the captured amount of the captured ones.
+type CurrencyReport struct {
+	Currency string                         `json:"currency"`
+	Count    int                            `json:"count"`
+	Total    money.Money                    `json:"total"`
+	ByStatus map[PaymentStatus]StatusTotals `json:"by_status"`
+}
+
+// StatusTotals sums the payments of one currency in one status
+type StatusTotals struct {
+	Count int         `json:"count"`
+	Total money.Money `json:"total"`
+}
+
+// GetPaymentsReport sums every stored payment grouped by currency and status
+func (s *serviceImpl) GetPaymentsReport(ctx context.Context, request GetPaymentsReportRequest) (GetPaymentsReportResponse, error) {
+	stored, err := s.payments.List(ctx)
+	if err != nil {
+		logger.Error(err.Error())
+		return GetPaymentsReportResponse{}, err
+	}
+
+	reports := make(map[string]*CurrencyReport)
+	for _, paymentStored := range stored {
+		var payment Payment
+		if err := json.Unmarshal([]byte(paymentStored), &payment); err != nil {
+			logger.Error(fmt.Errorf("Error unmarshalling payment: %s", err.Error()).Error())
+			continue
+		}
+
+		currency := money.NormalizeCurrency(payment.Price.Currency)
+		report, ok := reports[currency]
+		if !ok {
+			zero := money.Money{Currency: currency}
+			report = &CurrencyReport{Currency: currency, Total: zero, ByStatus: make(map[PaymentStatus]StatusTotals)}
+			reports[currency] = report
+		}
+
+		// a partial capture only charged part of the price
+		amount := payment.Price
+		if payment.CapturedAmount != nil {
+			amount = *payment.CapturedAmount
+		}
+
+		if charged(payment.Status) {
+			if report.Total, err = report.Total.Add(amount); err != nil {
+				return GetPaymentsReportResponse{}, err
+			}
+		}
+		report.Count++
+
+		byStatus, ok := report.ByStatus[payment.Status]
+		if !ok {
+			byStatus.Total = money.Money{Currency: currency}
+		}
+		if byStatus.Total, err = byStatus.Total.Add(amount); err != nil {
+			return GetPaymentsReportResponse{}, err
+		}
+		byStatus.Count++
+		report.ByStatus[payment.Status] = byStatus
+	}
+
+	response := GetPaymentsReportResponse{Currencies: make([]CurrencyReport, 0, len(reports))}
+	for _, report := range reports {
+		response.Currencies = append(response.Currencies, *report)
+	}
+	sort.Slice(response.Currencies, func(i, j int) bool {
+		return response.Currencies[i].Currency < response.Currencies[j].Currency
+	})
+	return response, nil
+}
+
+// charged reports whether the customer was charged the payment amount
+func charged(status PaymentStatus) bool {
+	return status == PaymentStatusPaid || status == PaymentStatusCaptured
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/money"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestGetPaymentsReport(t *testing.T) {
+	s, st := newTestService(t, DefaultConfig(), WithProvider(&testProvider{}))
+	ctx := context.Background()
+	brl := func(amount string) money.Money {
+		return money.Money{Amount: decimal.RequireFromString(amount), Currency: "BRL"}
+	}
+	captured := brl("5.00")
+
+	for _, payment := range []Payment{
+		{Status: PaymentStatusPaid, Price: brl("10.00")},
+		{Status: PaymentStatusCaptured, Price: brl("20.00"), CapturedAmount: &captured},
+		{Status: PaymentStatusFailed, Price: brl("40.00")},
+		{Status: PaymentStatusPending, Price: brl("80.00")},
+		{Status: PaymentStatusPaid, Price: money.Money{Amount: decimal.NewFromInt(1000), Currency: "JPY"}},
+	} {
+		payment.ID, payment.OrderID = uuid.New(), uuid.New()
+		value, err := json.Marshal(payment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := st.Set(ctx, payment.ID.String(), value, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := s.GetPaymentsReport(ctx, GetPaymentsReportRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Currencies) != 2 {
+		t.Fatalf("currencies = %+v, want BRL and JPY", report.Currencies)
+	}
+
+	reais := report.Currencies[0]
+	if reais.Currency != "BRL" || reais.Count != 4 {
+		t.Errorf("BRL report counts %d payments in %s, want 4", reais.Count, reais.Currency)
+	}
+	// only the paid price and the captured amount were charged
+	if !reais.Total.Amount.Equal(decimal.RequireFromString("15")) {
+		t.Errorf("BRL total = %s, want 15.00", reais.Total)
+	}
+	for status, want := range map[PaymentStatus]string{
+		PaymentStatusPaid:     "10",
+		PaymentStatusCaptured: "5",
+		PaymentStatusFailed:   "40",
+		PaymentStatusPending:  "80",
+	} {
+		if got := reais.ByStatus[status]; got.Count != 1 || !got.Total.Amount.Equal(decimal.RequireFromString(want)) {
+			t.Errorf("BRL %s = %d payments of %s, want one of %s", status, got.Count, got.Total, want)
+		}
+	}
+
+	yen := report.Currencies[1]
+	if yen.Currency != "JPY" || yen.Count != 1 || !yen.Total.Amount.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("JPY report = %+v, want one payment of 1000", yen)
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+)
+
+// StartExpiringPayments expires payments until SIGINT or SIGTERM
+func (s *serviceImpl) StartExpiringPayments() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s.ExpirePayments(ctx)
+}
+
+// ExpirePayments checks for expired payments every scheduler interval until ctx is done
+func (s *serviceImpl) ExpirePayments(ctx context.Context) {
+	interval := s.cfg.Scheduler.Interval
+	if interval <= 0 {
+		interval = DefaultConfig().Scheduler.Interval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logger.Info("Initializing payments expiry...")
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Shutting down payments expiry...")
+			return
+		case now := <-ticker.C:
+			s.expirePendingPayments(ctx, now)
+			s.expireAuthorizations(ctx, now)
+		}
+	}
+}
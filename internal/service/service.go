@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/SOAT1StackGoLang/msvc-payments/pkg/messages"
 	"math/rand"
@@ -10,8 +11,8 @@ import (
 
 	"github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore"
 	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/money"
 	"github.com/google/uuid"
-	"github.com/shopspring/decimal"
 )
 
 // define the Request and Response types here
@@ -43,37 +44,103 @@ type Service interface {
 	CreatePayment(ctx context.Context, request CreatePaymentRequest) (CreatePaymentResponse, error)
 	UpdatePayment(ctx context.Context, request UpdatePaymentRequest) (UpdatePaymentResponse, error)
 	GetPayment(ctx context.Context, request GetPaymentRequest) (GetPaymentResponse, error)
+	GetPaymentsReport(ctx context.Context, request GetPaymentsReportRequest) (GetPaymentsReportResponse, error)
+	ListQuarantinedMessages(ctx context.Context, request ListQuarantinedMessagesRequest) (ListQuarantinedMessagesResponse, error)
+	ReplayQuarantinedMessage(ctx context.Context, request ReplayQuarantinedMessageRequest) (ReplayQuarantinedMessageResponse, error)
+	DiscardQuarantinedMessage(ctx context.Context, request DiscardQuarantinedMessageRequest) (DiscardQuarantinedMessageResponse, error)
+	CreatePaymentAttempt(ctx context.Context, request CreatePaymentAttemptRequest) (CreatePaymentAttemptResponse, error)
+	CapturePayment(ctx context.Context, request CapturePaymentRequest) (CapturePaymentResponse, error)
+	VoidPayment(ctx context.Context, request VoidPaymentRequest) (VoidPaymentResponse, error)
 	StartProcessingPayments()
 	StartConsumingPaymentsRequests()
+	StartExpiringPayments()
+	// ProcessPayments, ConsumePaymentsRequests and ExpirePayments run the background work until ctx is done
+	ProcessPayments(ctx context.Context)
+	ConsumePaymentsRequests(ctx context.Context)
+	ExpirePayments(ctx context.Context)
 }
 
 type serviceImpl struct {
-	redisClient datastore.RedisStore
+	payments datastore.PaymentRepository
+	queue    datastore.WorkQueue
+	bus      datastore.MessageBus
+	// creator is set when the repository is also the queue and can create payments atomically
+	creator datastore.PaymentCreator
+	// inbox is set when the queue backend can record the processed messages
+	inbox    datastore.Inbox
+	provider Provider
+	// authorizer is set when the provider can authorize and capture payments
+	authorizer Authorizer
+	// watchers wakes the requests waiting for a payment to be final
+	watchers *statusWatchers
+	cfg      Config
 }
 
-func NewService(redisStore datastore.RedisStore) Service {
-	return &serviceImpl{redisClient: redisStore}
+// NewService creates the payment service on top of its storage abstractions.
+// A datastore.RedisStore satisfies all three, but each can be swapped independently.
+// Payments are charged by the mock provider unless WithProvider is given.
+func NewService(payments datastore.PaymentRepository, queue datastore.WorkQueue, bus datastore.MessageBus, cfg Config, opts ...Option) Service {
+	s := &serviceImpl{payments: payments, queue: queue, bus: bus, cfg: cfg, provider: NewMockProvider(cfg.Provider),
+		watchers: newStatusWatchers()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if creator, ok := payments.(datastore.PaymentCreator); ok && any(payments) == any(queue) {
+		s.creator = creator
+	}
+	if inbox, ok := queue.(datastore.Inbox); ok {
+		s.inbox = inbox
+	}
+	if authorizer, ok := s.provider.(Authorizer); ok {
+		s.authorizer = authorizer
+	}
+	return s
 }
 
 type Payment struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	Price     decimal.Decimal
+	Price     money.Money
 	OrderID   uuid.UUID
 	Status    PaymentStatus
+	// Version is incremented on every write, it backs the ETag of the payment
+	Version int64
+	// Attempts are the charges made at the provider, oldest first
+	Attempts []Attempt `json:",omitempty"`
+	// DeclineReason is the reason of the last failed attempt, cleared when the payment is retried
+	DeclineReason DeclineReason `json:",omitempty"`
+	// ExpiresAt is when a pending payment expires, nil when pending payments don't expire
+	ExpiresAt *time.Time `json:",omitempty"`
+	// CaptureMode is empty for automatic capture
+	CaptureMode CaptureMode `json:",omitempty"`
+	// AuthorizationExpiresAt is when an authorized payment is voided unless it is captured
+	AuthorizationExpiresAt *time.Time `json:",omitempty"`
+	// CapturedAmount is the amount charged by the capture, at most the price
+	CapturedAmount *money.Money `json:",omitempty"`
+	// CorrelationID and CausationID come from the envelope of the message that requested the payment
+	CorrelationID string `json:",omitempty"`
+	CausationID   string `json:",omitempty"`
 }
 
 func PaymentStatusChangedMessageFromPayment(p Payment) messages.PaymentStatusChangedMessage {
+	price := p.Price
 	return messages.PaymentStatusChangedMessage{
 		ID:        p.ID.String(),
 		UpdatedAt: p.UpdatedAt.Format(time.RFC3339),
 		OrderID:   p.OrderID.String(),
 		Status:    string(p.Status),
+		Price:     &price,
+
+		DeclineReason:  string(p.DeclineReason),
+		CapturedAmount: p.CapturedAmount,
 	}
 
 }
-func PaymentFromPaymentCreationRequestMessage(p messages.PaymentCreationRequestMessage) (*Payment, error) {
+
+// PaymentFromPaymentCreationRequestMessage converts a creation request into a payment of the given status,
+// the order status of the message is resolved by the caller through the configured mapping
+func PaymentFromPaymentCreationRequestMessage(p messages.PaymentCreationRequestMessage, status PaymentStatus) (*Payment, error) {
 	id, err := uuid.Parse(p.ID)
 	if err != nil {
 		return nil, err
@@ -89,31 +156,22 @@ func PaymentFromPaymentCreationRequestMessage(p messages.PaymentCreationRequestM
 		return nil, err
 	}
 
-	status := paymentStatusFromOrderStatus(p.Status)
+	price, err := p.PriceMoney()
+	if err != nil {
+		return nil, err
+	}
 
 	return &Payment{
-		ID:        id,
-		CreatedAt: createdAt,
-		UpdatedAt: time.Now(),
-		Price:     decimal.NewFromFloat(p.Price),
-		OrderID:   orderID,
-		Status:    status,
+		ID:          id,
+		CreatedAt:   createdAt,
+		UpdatedAt:   time.Now(),
+		Price:       price,
+		OrderID:     orderID,
+		Status:      status,
+		CaptureMode: CaptureMode(p.CaptureMode),
 	}, nil
 }
 
-func paymentStatusFromOrderStatus(status string) PaymentStatus {
-	switch status {
-	case "Aberto":
-		return PaymentStatusPending
-	case "Aguardando Pagamento":
-		return PaymentStatusPending
-	case "Recebido":
-		return PaymentStatusPaid
-	default:
-		return PaymentStatusClosed
-	}
-}
-
 type PaymentStatus string
 
 const (
@@ -121,30 +179,62 @@ const (
 	PaymentStatusPending PaymentStatus = "pending"
 	PaymentStatusFailed  PaymentStatus = "failed"
 	PaymentStatusClosed  PaymentStatus = "closed"
+	// PaymentStatusProcessing holds a pending payment while the provider charges or authorizes it
+	PaymentStatusProcessing PaymentStatus = "processing"
+	// PaymentStatusAuthorized holds the funds of a payment with manual capture until it is captured or voided
+	PaymentStatusAuthorized PaymentStatus = "authorized"
+	PaymentStatusCaptured   PaymentStatus = "captured"
+	PaymentStatusVoided     PaymentStatus = "voided"
+	// PaymentStatusCapturing and PaymentStatusVoiding hold an authorized payment while the provider captures or voids it
+	PaymentStatusCapturing PaymentStatus = "capturing"
+	PaymentStatusVoiding   PaymentStatus = "voiding"
+	// PaymentStatusExpired is reached by pending payments that were not charged within the pending TTL
+	PaymentStatusExpired PaymentStatus = "expired"
+)
+
+// CaptureMode tells whether a payment is charged at once or authorized and captured later
+type CaptureMode string
+
+const (
+	// CaptureModeAutomatic charges the payment when it is processed, it is the default
+	CaptureModeAutomatic CaptureMode = "automatic"
+	// CaptureModeManual only authorizes the payment when it is processed, it is charged by CapturePayment
+	CaptureModeManual CaptureMode = "manual"
 )
 
 type CreatePaymentRequest struct {
 	Payment Payment `json:"payment"`
+	// Wait holds the response until the payment is final or Wait elapses, taken from the wait query parameter
+	Wait time.Duration `json:"-"`
 }
 
 type CreatePaymentResponse struct {
 	PaymentID uuid.UUID     `json:"payment_id"`
 	Status    PaymentStatus `json:"status"`
+	// PaymentError is the decline reason of a payment that failed while the request waited
+	PaymentError string `json:"payment_error,omitempty"`
 }
 
 type UpdatePaymentRequest struct {
 	PaymentID     uuid.UUID     `json:"payment_id"`
 	PaymentStatus PaymentStatus `json:"payment_status"`
+	// IfMatch is the version the payment must still be at, taken from the If-Match header
+	IfMatch *int64 `json:"-"`
 }
 
 type UpdatePaymentResponse struct {
 	PaymentID    uuid.UUID     `json:"payment_id"`
 	Status       PaymentStatus `json:"status"`
 	PaymentError string        `json:"payment_error,omitempty"`
+	Version      int64         `json:"version"`
 }
 
 type GetPaymentRequest struct {
 	PaymentID uuid.UUID `json:"payment_id"`
+	// WaitFor is WaitForFinal to hold the response until the payment is final, for at most Wait
+	// or the configured maximum. Both are taken from the wait_for and wait query parameters.
+	WaitFor string        `json:"-"`
+	Wait    time.Duration `json:"-"`
 }
 
 type GetPaymentResponse struct {
@@ -157,15 +247,28 @@ type GetPaymentResponse struct {
 
 // CreatePayment creates a new payment
 func (s *serviceImpl) CreatePayment(ctx context.Context, request CreatePaymentRequest) (CreatePaymentResponse, error) {
-	// Validate UUID
-	if request.Payment.ID == uuid.Nil {
-		logger.Error("Invalid UUID")
-		return CreatePaymentResponse{}, fmt.Errorf("invalid uuid")
+	// Validate the request
+	if err := s.validateCreatePaymentRequest(request); err != nil {
+		logger.Error(err.Error())
+		return CreatePaymentResponse{}, err
 	}
 	// set the payment status to pending
 	request.Payment.Status = PaymentStatusPending
+	request.Payment.Version = 1
+	request.Payment.Attempts = nil
+	request.Payment.DeclineReason = ""
+	request.Payment.AuthorizationExpiresAt = nil
+	request.Payment.CapturedAmount = nil
+	// normalize the currency of the price, the amount is already rounded
+	price, err := request.Payment.Price.Round()
+	if err != nil {
+		logger.Error(err.Error())
+		return CreatePaymentResponse{}, err
+	}
+	request.Payment.Price = price
 	request.Payment.CreatedAt = time.Now()
 	request.Payment.UpdatedAt = time.Now()
+	request.Payment.ExpiresAt = s.pendingExpiry(request.Payment.CreatedAt)
 	// store the payment in the datastore
 	// Convert the payment to a JSON string
 	jsonString, err := json.Marshal(request.Payment)
@@ -173,154 +276,172 @@ func (s *serviceImpl) CreatePayment(ctx context.Context, request CreatePaymentRe
 		logger.Error(err.Error())
 		return CreatePaymentResponse{}, err
 	}
-	// check if the payment already exists
-	exists, err := s.redisClient.Exists(ctx, request.Payment.ID.String())
-	if exists {
-		logger.Error("Payment already exists")
-		return CreatePaymentResponse{}, fmt.Errorf("payment already exists")
-	} else if err != nil {
+	// store the payment and place it in the pending queue
+	created, err := s.createPayment(ctx, request.Payment, jsonString)
+	if err != nil {
 		logger.Error(err.Error())
 		return CreatePaymentResponse{}, err
 	}
+	if !created {
+		logger.Error("Payment already exists")
+		return CreatePaymentResponse{}, ErrPaymentAlreadyExists
+	}
+	response := CreatePaymentResponse{PaymentID: request.Payment.ID, Status: PaymentStatusPending}
+	if request.Wait > 0 {
+		payment, err := s.waitForFinal(ctx, request.Payment.ID, request.Wait)
+		if err != nil {
+			// the payment is created, it is reported as pending
+			logger.Error("failed waiting for payment", request.Payment.ID.String(), err.Error())
+			return response, nil
+		}
+		response.Status = payment.Status
+		response.PaymentError = string(payment.DeclineReason)
+	}
+	return response, nil
+}
 
-	err = s.redisClient.Set(ctx, request.Payment.ID.String(), jsonString, 0)
-	if err != nil {
-		logger.Error(err.Error())
-		return CreatePaymentResponse{}, err
+// createPayment stores the payment and places it in the pending queue, reporting false if it already exists.
+// An order has a single active payment: when the store creates payments atomically the order index is moved
+// to the payment in the same step, otherwise the repository rejects a second active payment by itself.
+func (s *serviceImpl) createPayment(ctx context.Context, payment Payment, value []byte) (bool, error) {
+	for attempt := 0; attempt < maxWriteAttempts; attempt++ {
+		op := datastore.CreateOp{
+			Key:   payment.ID.String(),
+			Value: value,
+			Queue: s.cfg.Queues.Pending,
+		}
+		if s.creator != nil {
+			expect, err := s.orderExpectations(ctx, payment)
+			if err != nil {
+				return false, err
+			}
+			op.Indexes = map[string]string{orderIndexKey(payment.OrderID): payment.ID.String()}
+			op.Expect = expect
+		}
+
+		created, err := s.createAndEnqueue(ctx, op)
+		if errors.Is(err, datastore.ErrConflict) {
+			logger.Debug("order", payment.OrderID.String(), "changed concurrently, retrying")
+			continue
+		}
+		return created, activePaymentError(err, payment.OrderID)
 	}
-	// place the payment in the redis queue
-	err = s.redisClient.LPush(ctx, "payment_pending_queue", request.Payment.ID.String())
+	return false, ErrVersionConflict
+}
+
+// createAndEnqueue stores a new payment and pushes it to the queue, reporting false if it already exists.
+// When the repository is also the queue the store does it atomically in one step,
+// otherwise the record is created only if absent and deleted again if the push fails.
+func (s *serviceImpl) createAndEnqueue(ctx context.Context, op datastore.CreateOp) (bool, error) {
+	if s.creator != nil {
+		return s.creator.CreateAndEnqueue(ctx, op)
+	}
+
+	created, err := s.payments.CompareAndSwap(ctx, op.Key, "", op.Value)
+	if err != nil || !created {
+		return false, err
+	}
+	err = s.queue.LPush(ctx, op.Queue, op.Key)
 	if err != nil {
 		// delete the payment from the datastore
 		pusherr := err
-		delerr := s.redisClient.Delete(ctx, request.Payment.ID.String())
+		delerr := s.payments.Delete(ctx, op.Key)
 		if delerr != nil {
 			// concat the errors
-			err = fmt.Errorf("PUSH: %s ---- DELETE: %s", pusherr.Error(), delerr.Error())
-			logger.Error(err.Error())
-			return CreatePaymentResponse{}, err
+			return false, fmt.Errorf("PUSH: %s ---- DELETE: %s", pusherr.Error(), delerr.Error())
 		}
-		return CreatePaymentResponse{}, err
+		return false, err
 	}
-	return CreatePaymentResponse{PaymentID: request.Payment.ID, Status: PaymentStatusPending}, nil
+	return true, nil
 }
 
 // MockPaymentProcess is a function that simulates a payment process and returns a mock payment status.
-// It takes a pointer to a PaymentStatus struct as input and returns a PaymentStatus value.
-// The function generates a random payment status between paid and failed, with a preference for paid.
-func MockPaymentProcess(p PaymentStatus) PaymentStatus {
-	// return a random payment status between paid and failed but prefer paid
+// It takes the probability of approval, between 0 and 1, as input and returns a PaymentStatus value.
+// The function generates a random payment status between paid and failed, weighted by successRate.
+func MockPaymentProcess(successRate float64) PaymentStatus {
+	// return a random payment status between paid and failed weighted by the success rate
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	if r.Float64() < 0.8 {
+	if r.Float64() < successRate {
 		return PaymentStatusPaid
 	}
 	return PaymentStatusFailed
 }
 
-// ProcessPayment processes a payment
+// ProcessPayment charges a pending payment with the provider and stores the attempt
 func (s *serviceImpl) ProcessPayment(ctx context.Context, paymentID uuid.UUID) (Payment, error) {
-	// get the payment from the datastore
-	paymentstored, err := s.redisClient.Get(ctx, paymentID.String())
-	if err != nil {
-		return Payment{}, err
-	}
-	// convert paymentstored to Payment type
-	var payment Payment
-	err = json.Unmarshal([]byte(paymentstored), &payment)
-	if err != nil {
-		logger.Error(fmt.Errorf("Error unmarshalling payment: %s", err.Error()).Error())
-		return Payment{}, err
-	}
-	// simulate a payment process
-	payment.Status = MockPaymentProcess(payment.Status)
-	return payment, nil
+	return s.chargePayment(ctx, paymentID, nil)
 }
 
 // UpdatePayment updates a payment
 func (s *serviceImpl) UpdatePayment(ctx context.Context, request UpdatePaymentRequest) (UpdatePaymentResponse, error) {
+	if err := s.validateUpdatePaymentRequest(request); err != nil {
+		return UpdatePaymentResponse{}, err
+	}
 	// get the payment from the datastore
 	if request.PaymentStatus == PaymentStatusClosed {
-		paymentStored, err := s.redisClient.Get(ctx, request.PaymentID.String())
-		if err != nil || paymentStored == "" {
-			return UpdatePaymentResponse{}, nil
+		// release the funds held by an authorization before closing it
+		if p, _, err := s.loadPayment(ctx, request.PaymentID); err == nil && p.Status == PaymentStatusAuthorized {
+			if _, err := s.voidAuthorization(ctx, request.PaymentID, request.IfMatch); err != nil {
+				return UpdatePaymentResponse{}, err
+			}
+			// the version was checked by the void
+			request.IfMatch = nil
+		}
+		payment, err := s.modifyPayment(ctx, request.PaymentID, request.IfMatch, func(p *Payment) error {
+			if err := checkTransition(p.Status, PaymentStatusClosed); err != nil {
+				return err
+			}
+			p.Status = PaymentStatusClosed
+			return nil
+		})
+		if err != nil {
+			return UpdatePaymentResponse{}, err
 		}
 
 		// Removing from queues
-		_ = s.redisClient.LREM(ctx, "payment_pending_queue", 0, request.PaymentID.String())
-		_ = s.redisClient.LREM(ctx, "payment_processing_queue", 0, request.PaymentID.String())
-
-		// payment string to bytes
-		paymentBytes, err := json.Marshal(paymentStored)
-
-		err = s.redisClient.Set(ctx, request.PaymentID.String(), paymentBytes, 0)
+		_ = s.queue.LREM(ctx, s.cfg.Queues.Pending, 0, request.PaymentID.String())
+		_ = s.queue.LREM(ctx, s.cfg.Queues.Processing, 0, request.PaymentID.String())
 
 		return UpdatePaymentResponse{
-			PaymentID: request.PaymentID,
-			Status:    request.PaymentStatus,
+			PaymentID:    request.PaymentID,
+			Status:       payment.Status,
+			PaymentError: string(payment.DeclineReason),
+			Version:      payment.Version,
 		}, nil
 	}
 
-	payment, err := s.ProcessPayment(ctx, request.PaymentID)
-	paymentResp := PaymentStatusChangedMessageFromPayment(payment)
-	pRespBytes, err := json.Marshal(paymentResp)
-	if err != nil {
-		return UpdatePaymentResponse{}, err
-	}
-	// convert payment
-	paymentBytes, err := json.Marshal(payment)
+	// charge the payment with the provider and store the attempt
+	payment, err := s.chargePayment(ctx, request.PaymentID, request.IfMatch)
 	if err != nil {
-		logger.Error(fmt.Errorf("Error marshalling payment: %s", err.Error()).Error())
 		return UpdatePaymentResponse{}, err
 	}
-	// notify channels of the payment status
-	switch payment.Status {
-	case PaymentStatusPaid:
-		err = s.redisClient.Publish(ctx, messages.PaymentStatusResponseChannel, pRespBytes)
-		if err != nil {
-			logger.Error(err.Error())
-			return UpdatePaymentResponse{}, err
-		}
-		// add the payment to the paid queue
-		err = s.redisClient.LPush(ctx, "payment_paid_queue", payment.ID.String())
-		if err != nil {
-			logger.Error(err.Error())
-			return UpdatePaymentResponse{}, err
-		}
-	case PaymentStatusFailed:
-		err = s.redisClient.Publish(ctx, messages.PaymentStatusResponseChannel, pRespBytes)
-		if err != nil {
-			logger.Error(err.Error())
-			return UpdatePaymentResponse{}, err
-		}
-		// add the payment to the failed queue
-		err = s.redisClient.LPush(ctx, "payment_failed_queue", payment.ID.String())
-		if err != nil {
-			logger.Error(err.Error())
-			return UpdatePaymentResponse{}, err
-		}
-	}
-
-	// store the payment in the datastore
-	err = s.redisClient.Set(ctx, request.PaymentID.String(), paymentBytes, 0)
-	if err != nil {
-		return UpdatePaymentResponse{}, err
-	}
-	return UpdatePaymentResponse{PaymentID: request.PaymentID, Status: request.PaymentStatus}, nil
+	return UpdatePaymentResponse{
+		PaymentID:    request.PaymentID,
+		Status:       payment.Status,
+		PaymentError: string(payment.DeclineReason),
+		Version:      payment.Version,
+	}, nil
 }
 
 // GetPayment gets a payment
 func (s *serviceImpl) GetPayment(ctx context.Context, request GetPaymentRequest) (GetPaymentResponse, error) {
-	// get the payment from the datastore
-	paymentStored, err := s.redisClient.Get(ctx, request.PaymentID.String())
-	if err != nil {
+	if err := s.validateGetPaymentRequest(request); err != nil {
 		return GetPaymentResponse{}, err
 	}
-	// convert paymentstored to Payment type
+	// get the payment from the datastore, waiting for it to be final when asked to
 	var payment Payment
-	err = json.Unmarshal([]byte(paymentStored), &payment)
+	var err error
+	if request.WaitFor == WaitForFinal {
+		wait := request.Wait
+		if wait == 0 {
+			wait = s.cfg.Wait.Max
+		}
+		payment, err = s.waitForFinal(ctx, request.PaymentID, wait)
+	} else {
+		payment, _, err = s.loadPayment(ctx, request.PaymentID)
+	}
 	if err != nil {
-		logger.Error(fmt.Errorf("Error unmarshalling payment: %s", err.Error()).Error())
 		return GetPaymentResponse{}, err
 	}
-	return GetPaymentResponse{Payment: payment, Status: payment.Status}, nil
+	return GetPaymentResponse{Payment: payment, Status: payment.Status, PaymentError: string(payment.DeclineReason)}, nil
 }
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/messages"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/money"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// testProvider answers every charge with result, or fails with err
+type testProvider struct {
+	mu      sync.Mutex
+	result  ChargeResult
+	err     error
+	charges int
+}
+
+func (p *testProvider) Charge(ctx context.Context, payment Payment) (ChargeResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.charges++
+	return p.result, p.err
+}
+
+// createTestPayment creates a pending payment of 19.90 BRL
+func createTestPayment(t *testing.T, s *serviceImpl) Payment {
+	t.Helper()
+	price, err := money.New(decimal.RequireFromString("19.90"), "BRL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment := Payment{ID: uuid.New(), OrderID: uuid.New(), Price: price}
+	resp, err := s.CreatePayment(context.Background(), CreatePaymentRequest{Payment: payment})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != PaymentStatusPending {
+		t.Fatalf("created payment is %s, want pending", resp.Status)
+	}
+	return payment
+}
+
+// inQueue reports whether the payment is in the queue
+func inQueue(t *testing.T, st datastore.RedisStore, queue string, paymentID uuid.UUID) bool {
+	t.Helper()
+	entries, err := st.LRange(context.Background(), queue, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry == paymentID.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCreateAndProcessPayment(t *testing.T) {
+	cfg := DefaultConfig()
+	tests := []struct {
+		name        string
+		result      ChargeResult
+		wantStatus  PaymentStatus
+		wantReason  DeclineReason
+		wantQueue   string
+		otherQueues []string
+	}{
+		{
+			name:        "approved",
+			result:      ChargeResult{Status: PaymentStatusPaid, Reference: "ref-1"},
+			wantStatus:  PaymentStatusPaid,
+			wantQueue:   cfg.Queues.Paid,
+			otherQueues: []string{cfg.Queues.Failed, cfg.Queues.Pending},
+		},
+		{
+			name:        "declined",
+			result:      ChargeResult{Status: PaymentStatusFailed, Reference: "ref-2", DeclineCode: "51", DeclineReason: DeclineReasonInsufficientFunds},
+			wantStatus:  PaymentStatusFailed,
+			wantReason:  DeclineReasonInsufficientFunds,
+			wantQueue:   cfg.Queues.Failed,
+			otherQueues: []string{cfg.Queues.Paid, cfg.Queues.Pending},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &testProvider{result: tt.result}
+			s, st := newTestService(t, cfg, WithProvider(provider))
+			ctx := context.Background()
+
+			created := createTestPayment(t, s)
+			if !inQueue(t, st, cfg.Queues.Pending, created.ID) {
+				t.Fatal("created payment is not pending in the queue")
+			}
+
+			if _, err := st.BLMOVE(ctx, cfg.Queues.Pending, cfg.Queues.Processing); err != nil {
+				t.Fatal(err)
+			}
+			processed, err := s.ProcessPayment(ctx, created.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if processed.Status != tt.wantStatus || processed.DeclineReason != tt.wantReason {
+				t.Errorf("processed payment is %s (%q), want %s (%q)", processed.Status, processed.DeclineReason, tt.wantStatus, tt.wantReason)
+			}
+
+			stored, _, err := s.loadPayment(ctx, created.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// created, reserved as processing, then charged
+			if stored.Status != tt.wantStatus || stored.Version != 3 {
+				t.Errorf("stored payment is %s at version %d, want %s at version 3", stored.Status, stored.Version, tt.wantStatus)
+			}
+			if len(stored.Attempts) != 1 || stored.Attempts[0].ProviderReference != tt.result.Reference {
+				t.Errorf("attempts = %+v, want one with reference %s", stored.Attempts, tt.result.Reference)
+			}
+			if provider.charges != 1 {
+				t.Errorf("provider charged %d times, want once", provider.charges)
+			}
+			if !inQueue(t, st, tt.wantQueue, created.ID) {
+				t.Errorf("payment is not in %s", tt.wantQueue)
+			}
+			for _, queue := range tt.otherQueues {
+				if inQueue(t, st, queue, created.ID) {
+					t.Errorf("payment is still in %s", queue)
+				}
+			}
+
+			// a payment is charged only once
+			if _, err := s.ProcessPayment(ctx, created.ID); !errors.Is(err, ErrInvalidTransition) {
+				t.Errorf("processing again: err = %v, want ErrInvalidTransition", err)
+			}
+		})
+	}
+}
+
+func TestCreatePaymentTwice(t *testing.T) {
+	s, _ := newTestService(t, DefaultConfig(), WithProvider(&testProvider{}))
+	payment := createTestPayment(t, s)
+
+	_, err := s.CreatePayment(context.Background(), CreatePaymentRequest{Payment: payment})
+	if !errors.Is(err, ErrPaymentAlreadyExists) {
+		t.Errorf("err = %v, want ErrPaymentAlreadyExists", err)
+	}
+}
+
+func TestVersionConflicts(t *testing.T) {
+	provider := &testProvider{result: ChargeResult{Status: PaymentStatusFailed, DeclineReason: DeclineReasonExpired}}
+	s, _ := newTestService(t, DefaultConfig(), WithProvider(provider))
+	ctx := context.Background()
+
+	payment := createTestPayment(t, s)
+	if _, err := s.ProcessPayment(ctx, payment.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	stale, current := int64(2), int64(3)
+	steps := []struct {
+		name        string
+		call        func(ifMatch *int64) error
+		ifMatch     *int64
+		wantErr     error
+		wantVersion int64
+	}{
+		{
+			name: "retry at a stale version", ifMatch: &stale, wantErr: ErrVersionConflict, wantVersion: 3,
+			call: func(ifMatch *int64) error {
+				_, err := s.CreatePaymentAttempt(ctx, CreatePaymentAttemptRequest{PaymentID: payment.ID, IfMatch: ifMatch})
+				return err
+			},
+		},
+		{
+			name: "retry at the current version", ifMatch: &current, wantVersion: 4,
+			call: func(ifMatch *int64) error {
+				_, err := s.CreatePaymentAttempt(ctx, CreatePaymentAttemptRequest{PaymentID: payment.ID, IfMatch: ifMatch})
+				return err
+			},
+		},
+		{
+			name: "close at the version before the retry", ifMatch: &current, wantErr: ErrVersionConflict, wantVersion: 4,
+			call: func(ifMatch *int64) error {
+				_, err := s.UpdatePayment(ctx, UpdatePaymentRequest{PaymentID: payment.ID, PaymentStatus: PaymentStatusClosed, IfMatch: ifMatch})
+				return err
+			},
+		},
+		{
+			name: "charge at the version before the retry", ifMatch: &current, wantErr: ErrVersionConflict, wantVersion: 4,
+			call: func(ifMatch *int64) error {
+				_, err := s.UpdatePayment(ctx, UpdatePaymentRequest{PaymentID: payment.ID, PaymentStatus: PaymentStatusPaid, IfMatch: ifMatch})
+				return err
+			},
+		},
+		{
+			name: "close without a version", wantVersion: 5,
+			call: func(ifMatch *int64) error {
+				_, err := s.UpdatePayment(ctx, UpdatePaymentRequest{PaymentID: payment.ID, PaymentStatus: PaymentStatusClosed, IfMatch: ifMatch})
+				return err
+			},
+		},
+	}
+	for _, step := range steps {
+		if err := step.call(step.ifMatch); !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: err = %v, want %v", step.name, err, step.wantErr)
+		}
+		stored, _, err := s.loadPayment(ctx, payment.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Version != step.wantVersion {
+			t.Errorf("%s: version = %d, want %d", step.name, stored.Version, step.wantVersion)
+		}
+	}
+	if provider.charges != 1 {
+		t.Errorf("provider charged %d times, want once", provider.charges)
+	}
+}
+
+func TestInboxSkipsDuplicateMessages(t *testing.T) {
+	s, _ := newTestService(t, DefaultConfig(), WithProvider(&testProvider{}))
+	ctx := context.Background()
+	paymentID := uuid.New()
+
+	var request messages.PaymentCreationRequestMessage
+	if err := json.Unmarshal([]byte(creationRequest(t, paymentID, "Aberto")), &request); err != nil {
+		t.Fatal(err)
+	}
+	enveloped, err := messages.Encode(messages.TypePaymentCreationRequest, 2, request, messages.WithID("message-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	closing := creationRequest(t, paymentID, "Cancelado")
+
+	deliveries := []struct {
+		name        string
+		payload     string
+		wantVersion int64
+		wantStatus  PaymentStatus
+	}{
+		{name: "creation", payload: string(enveloped), wantVersion: 1, wantStatus: PaymentStatusPending},
+		{name: "creation redelivered", payload: string(enveloped), wantVersion: 1, wantStatus: PaymentStatusPending},
+		{name: "closing", payload: closing, wantVersion: 2, wantStatus: PaymentStatusClosed},
+		{name: "closing redelivered", payload: closing, wantVersion: 2, wantStatus: PaymentStatusClosed},
+	}
+	before := duplicateMessages.Value()
+	for _, delivery := range deliveries {
+		quarantined, err := s.receivePaymentCreationRequest(ctx, delivery.payload)
+		if err != nil || quarantined != nil {
+			t.Fatalf("%s: %+v, %v", delivery.name, quarantined, err)
+		}
+		stored, _, err := s.loadPayment(ctx, paymentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Status != delivery.wantStatus || stored.Version != delivery.wantVersion {
+			t.Errorf("%s: payment is %s at version %d, want %s at version %d",
+				delivery.name, stored.Status, stored.Version, delivery.wantStatus, delivery.wantVersion)
+		}
+	}
+	if skipped := duplicateMessages.Value() - before; skipped != 2 {
+		t.Errorf("skipped %d duplicates, want 2", skipped)
+	}
+}
+
+// blockingProvider approves every charge once release is closed, it signals each charge on started
+type blockingProvider struct {
+	testProvider
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProvider) Charge(ctx context.Context, payment Payment) (ChargeResult, error) {
+	p.started <- struct{}{}
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		return ChargeResult{}, ctx.Err()
+	}
+	return p.testProvider.Charge(ctx, payment)
+}
+
+func TestConcurrentChargesReachTheProviderOnce(t *testing.T) {
+	provider := &blockingProvider{
+		testProvider: testProvider{result: ChargeResult{Status: PaymentStatusPaid}},
+		started:      make(chan struct{}, 2),
+		release:      make(chan struct{}),
+	}
+	s, _ := newTestService(t, DefaultConfig(), WithProvider(provider))
+	ctx := context.Background()
+	payment := createTestPayment(t, s)
+
+	processed := make(chan error, 1)
+	go func() {
+		_, err := s.ProcessPayment(ctx, payment.ID)
+		processed <- err
+	}()
+	<-provider.started
+
+	// the worker holds the payment, an update to paid can't charge it again
+	stored, _, err := s.loadPayment(ctx, payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != PaymentStatusProcessing {
+		t.Errorf("payment is %s while charged, want processing", stored.Status)
+	}
+	_, err = s.UpdatePayment(ctx, UpdatePaymentRequest{PaymentID: payment.ID, PaymentStatus: PaymentStatusPaid})
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("concurrent charge: err = %v, want ErrInvalidTransition", err)
+	}
+	_, err = s.UpdatePayment(ctx, UpdatePaymentRequest{PaymentID: payment.ID, PaymentStatus: PaymentStatusClosed})
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("close while charged: err = %v, want ErrInvalidTransition", err)
+	}
+
+	close(provider.release)
+	if err := <-processed; err != nil {
+		t.Fatal(err)
+	}
+	if provider.charges != 1 {
+		t.Errorf("provider charged %d times, want once", provider.charges)
+	}
+}
+
+func TestStoppedChargeReleasesThePayment(t *testing.T) {
+	provider := &blockingProvider{started: make(chan struct{}, 1), release: make(chan struct{})}
+	s, _ := newTestService(t, DefaultConfig(), WithProvider(provider))
+	payment := createTestPayment(t, s)
+
+	// the service stops while the provider is charging
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-provider.started
+		cancel()
+	}()
+	if _, err := s.ProcessPayment(ctx, payment.ID); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+
+	stored, _, err := s.loadPayment(context.Background(), payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != PaymentStatusPending || len(stored.Attempts) != 0 {
+		t.Errorf("payment is %s with %d attempts, want pending to be charged again", stored.Status, len(stored.Attempts))
+	}
+}
+
+// failingPushQueue is a work queue whose pushes fail
+type failingPushQueue struct {
+	datastore.WorkQueue
+}
+
+func (q failingPushQueue) LPush(ctx context.Context, key string, value interface{}) error {
+	return errors.New("queue unavailable")
+}
+
+func TestSeparateRepositoryQueueAndBus(t *testing.T) {
+	payments, queue, bus := datastore.NewMemoryStore(), datastore.NewMemoryStore(), datastore.NewMemoryStore()
+	cfg := DefaultConfig()
+	s := NewService(payments, queue, bus, cfg, WithProvider(&testProvider{})).(*serviceImpl)
+	ctx := context.Background()
+
+	created := createTestPayment(t, s)
+	if stored, err := payments.Get(ctx, created.ID.String()); err != nil || stored == "" {
+		t.Errorf("payment is not in the repository: %q, %v", stored, err)
+	}
+	if stored, err := queue.Get(ctx, created.ID.String()); err != nil || stored != "" {
+		t.Errorf("payment is stored in the queue backend: %q, %v", stored, err)
+	}
+	if !inQueue(t, queue, cfg.Queues.Pending, created.ID) || inQueue(t, payments, cfg.Queues.Pending, created.ID) {
+		t.Error("payment is not pending in the queue backend only")
+	}
+
+	// the payment is deleted again when it can't be enqueued
+	s = NewService(payments, failingPushQueue{queue}, bus, cfg, WithProvider(&testProvider{})).(*serviceImpl)
+	price, err := money.New(decimal.RequireFromString("19.90"), "BRL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lost := Payment{ID: uuid.New(), OrderID: uuid.New(), Price: price}
+	if _, err := s.CreatePayment(ctx, CreatePaymentRequest{Payment: lost}); err == nil {
+		t.Fatal("payment created without being enqueued")
+	}
+	if _, _, err := s.loadPayment(ctx, lost.ID); !errors.Is(err, ErrPaymentNotFound) {
+		t.Errorf("payment left in the repository: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+	"github.com/google/uuid"
+)
+
+// maxWriteAttempts bounds the read-modify-write retries of a payment that keeps changing concurrently
+const maxWriteAttempts = 5
+
+// loadPayment reads a payment and returns it along with the raw record used for compare-and-set writes
+func (s *serviceImpl) loadPayment(ctx context.Context, paymentID uuid.UUID) (Payment, string, error) {
+	paymentStored, err := s.payments.Get(ctx, paymentID.String())
+	if err != nil {
+		return Payment{}, "", err
+	}
+	if paymentStored == "" {
+		return Payment{}, "", ErrPaymentNotFound
+	}
+	// convert paymentstored to Payment type
+	var payment Payment
+	err = json.Unmarshal([]byte(paymentStored), &payment)
+	if err != nil {
+		logger.Error(fmt.Errorf("Error unmarshalling payment: %s", err.Error()).Error())
+		return Payment{}, "", err
+	}
+	return payment, paymentStored, nil
+}
+
+// modifyPayment applies mutate to the stored payment and writes it back only if nobody changed it meanwhile,
+// bumping its version. When expectedVersion is set the payment must still be at that version, otherwise
+// ErrVersionConflict is returned; without it concurrent changes are retried on the fresh payment.
+func (s *serviceImpl) modifyPayment(ctx context.Context, paymentID uuid.UUID, expectedVersion *int64, mutate func(*Payment) error) (Payment, error) {
+	for attempt := 0; attempt < maxWriteAttempts; attempt++ {
+		payment, raw, err := s.loadPayment(ctx, paymentID)
+		if err != nil {
+			return Payment{}, err
+		}
+		if expectedVersion != nil && payment.Version != *expectedVersion {
+			return Payment{}, ErrVersionConflict
+		}
+
+		if err := mutate(&payment); err != nil {
+			return Payment{}, err
+		}
+		payment.Version++
+		payment.UpdatedAt = time.Now()
+
+		paymentBytes, err := json.Marshal(payment)
+		if err != nil {
+			logger.Error(fmt.Errorf("Error marshalling payment: %s", err.Error()).Error())
+			return Payment{}, err
+		}
+
+		swapped, err := s.payments.CompareAndSwap(ctx, paymentID.String(), raw, paymentBytes)
+		if err != nil {
+			return Payment{}, err
+		}
+		if swapped {
+			return payment, nil
+		}
+		if expectedVersion != nil {
+			return Payment{}, ErrVersionConflict
+		}
+		logger.Debug("payment", paymentID.String(), "changed concurrently, retrying")
+	}
+	return Payment{}, ErrVersionConflict
+}
@@ -0,0 +1,30 @@
+package service
+
+import "fmt"
+
+// transitions lists the statuses each status can move to,
+// failed payments go back to pending when the customer retries them, pending payments are processing
+// while the provider charges them, and authorized payments are capturing or voiding while the provider
+// captures or voids them
+var transitions = map[PaymentStatus][]PaymentStatus{
+	PaymentStatusPending:    {PaymentStatusProcessing, PaymentStatusFailed, PaymentStatusClosed, PaymentStatusExpired},
+	PaymentStatusProcessing: {PaymentStatusPaid, PaymentStatusAuthorized, PaymentStatusFailed, PaymentStatusPending},
+	PaymentStatusPaid:       {PaymentStatusClosed},
+	PaymentStatusFailed:     {PaymentStatusPending, PaymentStatusClosed},
+	PaymentStatusAuthorized: {PaymentStatusCapturing, PaymentStatusVoiding},
+	PaymentStatusCapturing:  {PaymentStatusCaptured, PaymentStatusAuthorized},
+	PaymentStatusVoiding:    {PaymentStatusVoided, PaymentStatusAuthorized},
+	PaymentStatusCaptured:   {PaymentStatusClosed},
+	PaymentStatusVoided:     {PaymentStatusClosed},
+	PaymentStatusExpired:    {PaymentStatusClosed},
+}
+
+// checkTransition returns ErrInvalidTransition when a payment can't move from one status to the other
+func checkTransition(from, to PaymentStatus) error {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: payment is %s and can't become %s", ErrInvalidTransition, from, to)
+}
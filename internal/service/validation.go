@@ -0,0 +1,210 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/money"
+	"github.com/google/uuid"
+)
+
+// FieldError describes an invalid field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists the invalid fields of a request, it matches ErrValidationFailed
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + " " + f.Message
+	}
+	return fmt.Sprintf("%s: %s", ErrValidationFailed, strings.Join(msgs, "; "))
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidationFailed
+}
+
+// rule checks a value and describes what is wrong with it, an empty string means it is valid
+type rule[T any] func(T) string
+
+// fieldCheck validates one field of a request
+type fieldCheck func() *FieldError
+
+// field checks value against rules in order, reporting the first one that fails
+func field[T any](name string, value T, rules ...rule[T]) fieldCheck {
+	return func() *FieldError {
+		for _, r := range rules {
+			if msg := r(value); msg != "" {
+				return &FieldError{Field: name, Message: msg}
+			}
+		}
+		return nil
+	}
+}
+
+// validate runs every check and returns a *ValidationError with all the invalid fields
+func validate(checks ...fieldCheck) error {
+	var fields []FieldError
+	for _, check := range checks {
+		if fe := check(); fe != nil {
+			fields = append(fields, *fe)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: fields}
+}
+
+func requiredUUID(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return "is required"
+	}
+	return ""
+}
+
+func supportedCurrency(m money.Money) string {
+	if _, err := money.RuleFor(m.Currency); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func positiveAmount(m money.Money) string {
+	if !m.Amount.IsPositive() {
+		return "must be greater than zero"
+	}
+	return ""
+}
+
+func maxDecimalPlaces(m money.Money) string {
+	rule, err := money.RuleFor(m.Currency)
+	if err != nil {
+		// reported on the currency
+		return ""
+	}
+	if !m.Rounded() {
+		if rule.Increment.IsPositive() {
+			return fmt.Sprintf("must be a multiple of %s for %s", rule.Increment, money.NormalizeCurrency(m.Currency))
+		}
+		return fmt.Sprintf("must have at most %d decimal places for %s", rule.Exponent, money.NormalizeCurrency(m.Currency))
+	}
+	return ""
+}
+
+func nonNegative(d time.Duration) string {
+	if d < 0 {
+		return "must not be negative"
+	}
+	return ""
+}
+
+func waitFor(w string) string {
+	if w != "" && w != WaitForFinal {
+		return "must be " + WaitForFinal
+	}
+	return ""
+}
+
+// oneOf accepts only the given statuses
+func oneOf(statuses ...PaymentStatus) rule[PaymentStatus] {
+	return func(status PaymentStatus) string {
+		for _, allowed := range statuses {
+			if status == allowed {
+				return ""
+			}
+		}
+		names := make([]string, len(statuses))
+		for i, s := range statuses {
+			names[i] = string(s)
+		}
+		return fmt.Sprintf("must be one of %s", strings.Join(names, ", "))
+	}
+}
+
+// withinLimit rejects amounts above the configured maximum of their currency
+func (s *serviceImpl) withinLimit(m money.Money) string {
+	limit, ok := s.cfg.Limits.MaxAmounts[money.NormalizeCurrency(m.Currency)]
+	if ok && m.Amount.GreaterThan(limit) {
+		return fmt.Sprintf("must not exceed %s %s", limit, money.NormalizeCurrency(m.Currency))
+	}
+	return ""
+}
+
+// supportedCaptureMode accepts the automatic capture, and the manual one when the provider can authorize
+func (s *serviceImpl) supportedCaptureMode(mode CaptureMode) string {
+	switch mode {
+	case "", CaptureModeAutomatic:
+		return ""
+	case CaptureModeManual:
+		if s.authorizer == nil {
+			return "manual is not supported by the payment provider"
+		}
+		return ""
+	default:
+		return fmt.Sprintf("must be one of %s, %s", CaptureModeAutomatic, CaptureModeManual)
+	}
+}
+
+func (s *serviceImpl) validateCreatePaymentRequest(r CreatePaymentRequest) error {
+	return validate(
+		field("payment.ID", r.Payment.ID, requiredUUID),
+		field("payment.OrderID", r.Payment.OrderID, requiredUUID),
+		field("payment.Price.currency", r.Payment.Price, supportedCurrency),
+		field("payment.Price.amount", r.Payment.Price, positiveAmount, maxDecimalPlaces, s.withinLimit),
+		field("payment.CaptureMode", r.Payment.CaptureMode, s.supportedCaptureMode),
+		field("wait", r.Wait, nonNegative),
+	)
+}
+
+func (s *serviceImpl) validateUpdatePaymentRequest(r UpdatePaymentRequest) error {
+	return validate(
+		field("payment_id", r.PaymentID, requiredUUID),
+		field("payment_status", r.PaymentStatus, oneOf(PaymentStatusPaid, PaymentStatusFailed, PaymentStatusClosed)),
+	)
+}
+
+func (s *serviceImpl) validateGetPaymentRequest(r GetPaymentRequest) error {
+	return validate(
+		field("payment_id", r.PaymentID, requiredUUID),
+		field("wait_for", r.WaitFor, waitFor),
+		field("wait", r.Wait, nonNegative),
+	)
+}
+
+func (s *serviceImpl) validateCreatePaymentAttemptRequest(r CreatePaymentAttemptRequest) error {
+	return validate(
+		field("payment_id", r.PaymentID, requiredUUID),
+	)
+}
+
+func (s *serviceImpl) validateCapturePaymentRequest(r CapturePaymentRequest) error {
+	checks := []fieldCheck{field("payment_id", r.PaymentID, requiredUUID)}
+	if r.Amount != nil {
+		checks = append(checks,
+			field("amount.currency", *r.Amount, supportedCurrency),
+			field("amount.amount", *r.Amount, positiveAmount, maxDecimalPlaces),
+		)
+	}
+	return validate(checks...)
+}
+
+func (s *serviceImpl) validateVoidPaymentRequest(r VoidPaymentRequest) error {
+	return validate(
+		field("payment_id", r.PaymentID, requiredUUID),
+	)
+}
+
+func (s *serviceImpl) validateQuarantinedMessageID(id uuid.UUID) error {
+	return validate(
+		field("id", id, requiredUUID),
+	)
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/money"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestValidateCreatePaymentRequest(t *testing.T) {
+	s, _ := newTestService(t, DefaultConfig(), WithProvider(&testProvider{}))
+	price := func(amount, currency string) money.Money {
+		return money.Money{Amount: decimal.RequireFromString(amount), Currency: currency}
+	}
+	valid := func() CreatePaymentRequest {
+		return CreatePaymentRequest{Payment: Payment{ID: uuid.New(), OrderID: uuid.New(), Price: price("19.90", "BRL")}}
+	}
+
+	tests := []struct {
+		name       string
+		modify     func(*CreatePaymentRequest)
+		wantFields []FieldError
+	}{
+		{name: "valid", modify: func(*CreatePaymentRequest) {}},
+		{name: "lower case currency", modify: func(r *CreatePaymentRequest) { r.Payment.Price = price("1000", "jpy") }},
+		{
+			name: "missing IDs",
+			modify: func(r *CreatePaymentRequest) {
+				r.Payment.ID, r.Payment.OrderID = uuid.Nil, uuid.Nil
+			},
+			wantFields: []FieldError{
+				{Field: "payment.ID", Message: "is required"},
+				{Field: "payment.OrderID", Message: "is required"},
+			},
+		},
+		{
+			name:       "zero amount",
+			modify:     func(r *CreatePaymentRequest) { r.Payment.Price = price("0", "BRL") },
+			wantFields: []FieldError{{Field: "payment.Price.amount", Message: "must be greater than zero"}},
+		},
+		{
+			name:       "too many decimal places",
+			modify:     func(r *CreatePaymentRequest) { r.Payment.Price = price("19.905", "BRL") },
+			wantFields: []FieldError{{Field: "payment.Price.amount", Message: "must have at most 2 decimal places for BRL"}},
+		},
+		{
+			name:       "decimal places of a currency without minor units",
+			modify:     func(r *CreatePaymentRequest) { r.Payment.Price = price("10.5", "JPY") },
+			wantFields: []FieldError{{Field: "payment.Price.amount", Message: "must have at most 0 decimal places for JPY"}},
+		},
+		{
+			name:       "off the increment of the currency",
+			modify:     func(r *CreatePaymentRequest) { r.Payment.Price = price("1.02", "CHF") },
+			wantFields: []FieldError{{Field: "payment.Price.amount", Message: "must be a multiple of 0.05 for CHF"}},
+		},
+		{
+			name:       "above the limit",
+			modify:     func(r *CreatePaymentRequest) { r.Payment.Price = price("100000.01", "BRL") },
+			wantFields: []FieldError{{Field: "payment.Price.amount", Message: "must not exceed 100000 BRL"}},
+		},
+		{
+			name:   "unsupported currency",
+			modify: func(r *CreatePaymentRequest) { r.Payment.Price = price("10", "XYZ") },
+			wantFields: []FieldError{
+				{Field: "payment.Price.currency", Message: `unsupported currency "XYZ"`},
+			},
+		},
+		{
+			name:       "manual capture without an authorizer",
+			modify:     func(r *CreatePaymentRequest) { r.Payment.CaptureMode = CaptureModeManual },
+			wantFields: []FieldError{{Field: "payment.CaptureMode", Message: "manual is not supported by the payment provider"}},
+		},
+		{
+			name:       "unknown capture mode",
+			modify:     func(r *CreatePaymentRequest) { r.Payment.CaptureMode = "later" },
+			wantFields: []FieldError{{Field: "payment.CaptureMode", Message: "must be one of automatic, manual"}},
+		},
+		{
+			name:       "negative wait",
+			modify:     func(r *CreatePaymentRequest) { r.Wait = -time.Second },
+			wantFields: []FieldError{{Field: "wait", Message: "must not be negative"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := valid()
+			tt.modify(&request)
+			assertFieldErrors(t, s.validateCreatePaymentRequest(request), tt.wantFields)
+		})
+	}
+}
+
+func TestValidateRequests(t *testing.T) {
+	s, _ := newTestService(t, DefaultConfig(), WithProvider(&testProvider{}))
+	id := uuid.New()
+	amount := money.Money{Amount: decimal.RequireFromString("1.001"), Currency: "BRL"}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantFields []FieldError
+	}{
+		{
+			name:       "update to a status that can't be requested",
+			err:        s.validateUpdatePaymentRequest(UpdatePaymentRequest{PaymentID: id, PaymentStatus: PaymentStatusPending}),
+			wantFields: []FieldError{{Field: "payment_status", Message: "must be one of paid, failed, closed"}},
+		},
+		{
+			name: "update without a payment",
+			err:  s.validateUpdatePaymentRequest(UpdatePaymentRequest{PaymentStatus: PaymentStatusClosed}),
+			wantFields: []FieldError{
+				{Field: "payment_id", Message: "is required"},
+			},
+		},
+		{
+			name: "get waiting for an unknown state",
+			err:  s.validateGetPaymentRequest(GetPaymentRequest{PaymentID: id, WaitFor: "paid", Wait: -time.Second}),
+			wantFields: []FieldError{
+				{Field: "wait_for", Message: "must be final"},
+				{Field: "wait", Message: "must not be negative"},
+			},
+		},
+		{
+			name: "get waiting for the final state",
+			err:  s.validateGetPaymentRequest(GetPaymentRequest{PaymentID: id, WaitFor: WaitForFinal, Wait: time.Second}),
+		},
+		{
+			name:       "capture of a partial amount with too many decimal places",
+			err:        s.validateCapturePaymentRequest(CapturePaymentRequest{PaymentID: id, Amount: &amount}),
+			wantFields: []FieldError{{Field: "amount.amount", Message: "must have at most 2 decimal places for BRL"}},
+		},
+		{
+			name:       "void without a payment",
+			err:        s.validateVoidPaymentRequest(VoidPaymentRequest{}),
+			wantFields: []FieldError{{Field: "payment_id", Message: "is required"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertFieldErrors(t, tt.err, tt.wantFields)
+		})
+	}
+}
+
+// assertFieldErrors checks err is a *ValidationError with the fields, or nil when there are none
+func assertFieldErrors(t *testing.T, err error, want []FieldError) {
+	t.Helper()
+	if len(want) == 0 {
+		if err != nil {
+			t.Fatalf("err = %v, want nil", err)
+		}
+		return
+	}
+	if !errors.Is(err, ErrValidationFailed) {
+		t.Fatalf("err = %v, want ErrValidationFailed", err)
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("err = %T, want *ValidationError", err)
+	}
+	if !reflect.DeepEqual(validationErr.Fields, want) {
+		t.Errorf("fields = %+v, want %+v", validationErr.Fields, want)
+	}
+}
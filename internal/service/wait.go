@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/messages"
+	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+	"github.com/google/uuid"
+)
+
+// WaitForFinal waits for the payment to leave pending
+const WaitForFinal = "final"
+
+// final reports whether the payment is done with the provider. Authorized payments are final
+// as well, their capture is up to the caller.
+func final(status PaymentStatus) bool {
+	return status != PaymentStatusPending && status != PaymentStatusProcessing
+}
+
+// statusWatchers wakes the requests waiting on a payment when its status changes.
+// It follows PaymentStatusResponseChannel, so the changes made by other instances wake them as well.
+type statusWatchers struct {
+	mu sync.Mutex
+	// following is set while the subscription to the status channel is open
+	following bool
+	watchers  map[uuid.UUID]map[chan struct{}]struct{}
+}
+
+func newStatusWatchers() *statusWatchers {
+	return &statusWatchers{watchers: make(map[uuid.UUID]map[chan struct{}]struct{})}
+}
+
+// watchStatus returns a channel signaled when the status of the payment changes and a func to stop watching,
+// the status channel is subscribed on the first watch
+func (s *serviceImpl) watchStatus(paymentID uuid.UUID) (<-chan struct{}, func(), error) {
+	w := s.watchers
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.following {
+		// the subscription outlives the request, it is shared by all the waits
+		msgs, err := s.bus.Subscribe(context.Background(), messages.PaymentStatusResponseChannel)
+		if err != nil {
+			return nil, nil, err
+		}
+		w.following = true
+		go s.followStatuses(msgs)
+	}
+
+	changed := make(chan struct{}, 1)
+	if w.watchers[paymentID] == nil {
+		w.watchers[paymentID] = make(map[chan struct{}]struct{})
+	}
+	w.watchers[paymentID][changed] = struct{}{}
+
+	stop := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.watchers[paymentID], changed)
+		if len(w.watchers[paymentID]) == 0 {
+			delete(w.watchers, paymentID)
+		}
+	}
+	return changed, stop, nil
+}
+
+// followStatuses wakes the watchers of each payment whose status changed. When the subscription
+// closes every watcher is woken, and the next watch subscribes again.
+func (s *serviceImpl) followStatuses(msgs <-chan *datastore.Message) {
+	for msg := range msgs {
+		envelope, err := messages.Decode([]byte(msg.Payload), messages.TypePaymentStatusChanged)
+		if err != nil {
+			continue
+		}
+		var changed messages.PaymentStatusChangedMessage
+		if err := envelope.DecodePayload(&changed); err != nil {
+			continue
+		}
+		paymentID, err := uuid.Parse(changed.ID)
+		if err != nil {
+			continue
+		}
+		s.watchers.notify(func(id uuid.UUID) bool { return id == paymentID })
+	}
+
+	logger.Info("status subscription closed, waking the waiting requests")
+	s.watchers.mu.Lock()
+	s.watchers.following = false
+	s.watchers.mu.Unlock()
+	s.watchers.notify(func(uuid.UUID) bool { return true })
+}
+
+// notify signals the watchers of the matching payments without blocking
+func (w *statusWatchers) notify(match func(uuid.UUID) bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for id, watchers := range w.watchers {
+		if !match(id) {
+			continue
+		}
+		for changed := range watchers {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// waitForFinal reads the payment until it is final, for at most wait capped by the configured maximum.
+// It returns the payment as last read, still pending when the wait elapsed.
+func (s *serviceImpl) waitForFinal(ctx context.Context, paymentID uuid.UUID, wait time.Duration) (Payment, error) {
+	if wait > s.cfg.Wait.Max {
+		wait = s.cfg.Wait.Max
+	}
+
+	// watch before reading so that a change in between is not missed
+	changed, stop, err := s.watchStatus(paymentID)
+	if err != nil {
+		logger.Error("failed subscribing to payment statuses, not waiting:", err.Error())
+		payment, _, err := s.loadPayment(ctx, paymentID)
+		return payment, err
+	}
+	defer stop()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		payment, _, err := s.loadPayment(ctx, paymentID)
+		if err != nil || final(payment.Status) {
+			return payment, err
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return payment, nil
+		case <-ctx.Done():
+			return Payment{}, ctx.Err()
+		}
+	}
+}
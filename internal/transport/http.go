@@ -2,9 +2,13 @@
 package transport
 
 import (
+	"expvar"
+	"fmt"
 	"github.com/SOAT1StackGoLang/msvc-payments/internal/endpoint"
 	"github.com/gorilla/mux"
 	"net/http"
+	"strings"
+	"time"
 )
 
 // NewHTTPHandler returns a new HTTP handler that routes incoming requests to the appropriate endpoints.
@@ -13,30 +17,89 @@ import (
 // It returns an `http.Handler` that can be used to serve the HTTP requests.
 func NewHTTPHandler(endpoints endpoint.Endpoints) http.Handler {
 	r := mux.NewRouter()
+	// Errors are answered as RFC 7807 problems, unknown routes too
+	r.NotFoundHandler = endpoint.NotFoundHandler()
+	r.MethodNotAllowedHandler = endpoint.MethodNotAllowedHandler()
 	// Add other endpoints here
 
 	// Create Payment endpoint
 	r.Methods("POST").Path("/payments").Handler(endpoint.MakeCreatePaymentHandler(endpoints.CreatePayment))
 	// Get Payment endpoint
 	r.Methods("GET").Path("/payments").Handler(endpoint.MakeGetPaymentHandler(endpoints.GetPayment))
+	r.Methods("GET").Path("/payments/{id}").Handler(endpoint.MakeGetPaymentHandler(endpoints.GetPayment))
 	// Update Payment endpoint
 	r.Methods("PUT").Path("/payments").Handler(endpoint.MakeUpdatePaymentHandler(endpoints.UpdatePayment))
+	// Payment attempts endpoint, retries a failed payment
+	r.Methods("POST").Path("/payments/{id}/attempts").Handler(endpoint.MakeCreatePaymentAttemptHandler(endpoints.CreatePaymentAttempt))
+	// Capture and void endpoints of the authorized payments
+	r.Methods("POST").Path("/payments/{id}/capture").Handler(endpoint.MakeCapturePaymentHandler(endpoints.CapturePayment))
+	r.Methods("POST").Path("/payments/{id}/void").Handler(endpoint.MakeVoidPaymentHandler(endpoints.VoidPayment))
+	// Payments report endpoint
+	r.Methods("GET").Path("/reports/payments").Handler(endpoint.MakeGetPaymentsReportHandler(endpoints.GetPaymentsReport))
+	// Quarantine endpoints
+	r.Methods("GET").Path("/quarantine").Handler(endpoint.MakeListQuarantinedMessagesHandler(endpoints.ListQuarantinedMessages))
+	r.Methods("POST").Path("/quarantine/{id}/replay").Handler(endpoint.MakeReplayQuarantinedMessageHandler(endpoints.ReplayQuarantinedMessage))
+	r.Methods("DELETE").Path("/quarantine/{id}").Handler(endpoint.MakeDiscardQuarantinedMessageHandler(endpoints.DiscardQuarantinedMessage))
+	// Service metrics, such as the duplicate messages counter
+	r.Methods("GET").Path("/debug/vars").Handler(metricsHandler())
 	return r
 }
 
+// metricsPrefix selects the expvar variables published by the service. The others, such as cmdline
+// and memstats, describe the process and are not served.
+const metricsPrefix = "payments_"
+
+// metricsHandler serves the service variables of expvar in its JSON format
+func metricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprint(w, "{")
+		first := true
+		expvar.Do(func(kv expvar.KeyValue) {
+			if !strings.HasPrefix(kv.Key, metricsPrefix) {
+				return
+			}
+			if !first {
+				fmt.Fprint(w, ",")
+			}
+			first = false
+			fmt.Fprintf(w, "\n%q: %s", kv.Key, kv.Value)
+		})
+		fmt.Fprint(w, "\n}\n")
+	})
+}
+
+// ServerOptions holds the address and timeouts of the HTTP server.
+// Zero timeouts mean no timeout, as in net/http.
+type ServerOptions struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+}
+
 // NewHTTPServer creates a new HTTP server that listens on the specified address
 // and handles requests using the provided handler.
 //
 // Parameters:
-// - addr: The address to listen on (e.g., "localhost:8080").
+// - opts: The address to listen on (e.g., "localhost:8080") and the server timeouts.
 // - handler: The http.Handler to handle incoming requests.
 //
 // Example usage:
 //
-//	NewHTTPServer("localhost:8080", myHandler)
+//	NewHTTPServer(ServerOptions{Addr: "localhost:8080"}, myHandler)
 //
-// Note: This function blocks indefinitely, so it should typically be called in a
-// separate goroutine.
-func NewHTTPServer(addr string, handler http.Handler) {
-	http.ListenAndServe(addr, handler)
+// Note: This function blocks until the server stops, so it should typically be called in a
+// separate goroutine. The returned error is never nil.
+func NewHTTPServer(opts ServerOptions, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              opts.Addr,
+		Handler:           handler,
+		ReadTimeout:       opts.ReadTimeout,
+		ReadHeaderTimeout: opts.ReadHeaderTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+	}
+	return server.ListenAndServe()
 }
@@ -0,0 +1,32 @@
+package transport
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testCounter is published once, expvar panics when a name is reused
+var testCounter = expvar.NewInt("payments_test_counter")
+
+func TestMetricsHandlerServesOnlyServiceVariables(t *testing.T) {
+	testCounter.Set(3)
+
+	rec := httptest.NewRecorder()
+	metricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("invalid JSON %q: %v", rec.Body.String(), err)
+	}
+	if string(vars["payments_test_counter"]) != "3" {
+		t.Errorf("payments_test_counter = %s, want 3", vars["payments_test_counter"])
+	}
+	for _, name := range []string{"cmdline", "memstats"} {
+		if _, ok := vars[name]; ok {
+			t.Errorf("%s is served", name)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// Package apitest runs the payments service in process for the test suites of its clients.
+//
+// The server uses the real HTTP handlers and background workers on top of the in-memory store,
+// with a provider whose outcome is chosen by the test, for charges and authorizations alike:
+//
+//	srv := apitest.NewServer()
+//	defer srv.Close()
+//	srv.SetOutcome(apitest.OutcomeDecline)
+//	client := srv.Client()
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/internal/endpoint"
+	"github.com/SOAT1StackGoLang/msvc-payments/internal/service"
+	"github.com/SOAT1StackGoLang/msvc-payments/internal/transport"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/api"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore"
+	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/money"
+	kitlog "github.com/go-kit/log"
+	"github.com/google/uuid"
+)
+
+// Outcome is what the fake provider does with the payments it charges
+type Outcome string
+
+const (
+	// OutcomeApprove marks every payment as paid, it is the default
+	OutcomeApprove Outcome = "approve"
+	// OutcomeDecline marks every payment as failed, with the reason set by SetDeclineReason
+	OutcomeDecline Outcome = "decline"
+	// OutcomeTimeout makes the provider fail with ErrProviderTimeout after ProviderTimeout,
+	// the payments fail with the timeout decline reason
+	OutcomeTimeout Outcome = "timeout"
+)
+
+// ProviderTimeout is how long the provider hangs before timing out with OutcomeTimeout
+const ProviderTimeout = 100 * time.Millisecond
+
+// ErrProviderTimeout is returned by the provider with OutcomeTimeout
+var ErrProviderTimeout = fmt.Errorf("apitest: provider timed out: %w", context.DeadlineExceeded)
+
+// RecordedRequest is a request received by the server
+type RecordedRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   []byte
+}
+
+// Server is the payments service running in process, its fields must not be changed
+type Server struct {
+	// URL is the base URL of the server, as given to api.NewClientV2
+	URL string
+	// Store holds the payments, queues and messages of the server
+	Store datastore.RedisStore
+
+	httpServer *httptest.Server
+	cancel     context.CancelFunc
+	done       sync.WaitGroup
+
+	mu            sync.Mutex
+	outcome       Outcome
+	declineReason api.DeclineReason
+	status        int
+	delay         time.Duration
+	requests      []RecordedRequest
+}
+
+// NewServer starts a server with an empty store, approving every payment
+func NewServer() *Server {
+	// the service logs through the package logger, keep the one of the test suite if it set one
+	if logger.ErrorLogger == nil {
+		logger.InitializeLoggerWithOptions("error", "logfmt")
+	}
+
+	s := &Server{Store: datastore.NewMemoryStore(), outcome: OutcomeApprove, declineReason: api.DeclineReasonInsufficientFunds}
+
+	cfg := service.DefaultConfig()
+	// check for expired authorizations often, so tests don't wait for them
+	cfg.Scheduler.Interval = 50 * time.Millisecond
+	svc := service.NewService(s.Store, s.Store, s.Store, cfg, service.WithProvider(provider{s}))
+	handler := transport.NewHTTPHandler(endpoint.MakeEndpoints(svc))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done.Add(3)
+	go func() {
+		defer s.done.Done()
+		svc.ProcessPayments(ctx)
+	}()
+	go func() {
+		defer s.done.Done()
+		svc.ConsumePaymentsRequests(ctx)
+	}()
+	go func() {
+		defer s.done.Done()
+		svc.ExpirePayments(ctx)
+	}()
+
+	s.httpServer = httptest.NewServer(s.intercept(handler))
+	s.URL = s.httpServer.URL
+	return s
+}
+
+// Close stops the server and its workers
+func (s *Server) Close() {
+	s.httpServer.Close()
+	s.cancel()
+	s.done.Wait()
+	_ = s.Store.CloseClient()
+}
+
+// Client returns a v2 client of the server, without retries unless opts set a policy
+func (s *Server) Client(opts ...api.ClientOption) api.PaymentAPIV2 {
+	opts = append([]api.ClientOption{
+		api.WithHTTPClient(s.httpServer.Client()),
+		api.WithRetryPolicy(api.RetryPolicy{MaxAttempts: 1}),
+	}, opts...)
+	return api.NewClientV2(s.URL, opts...)
+}
+
+// LegacyClient returns a PaymentAPI client of the server
+func (s *Server) LegacyClient() api.PaymentAPI {
+	return api.NewClient(s.URL, kitlog.NewNopLogger())
+}
+
+// StatusSubscriber returns a subscriber of the status changes published by the server
+func (s *Server) StatusSubscriber(opts ...api.SubscriberOption) *api.StatusSubscriber {
+	return api.NewStatusSubscriber(s.Store, opts...)
+}
+
+// SetOutcome chooses what the provider does with the next payments
+func (s *Server) SetOutcome(outcome Outcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outcome = outcome
+}
+
+// SetDeclineReason chooses the reason of the payments declined with OutcomeDecline, insufficient_funds by default
+func (s *Server) SetDeclineReason(reason api.DeclineReason) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.declineReason = reason
+}
+
+// FailRequests makes every request answer with a problem of status, such as 500, without reaching the handlers.
+// Its code is internal_error for 5xx statuses and invalid_request otherwise. Zero lets requests through again.
+func (s *Server) FailRequests(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// DelayResponses holds every request for delay before it is handled, to trigger client timeouts
+func (s *Server) DelayResponses(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = delay
+}
+
+// Requests returns the requests received so far, oldest first
+func (s *Server) Requests() []RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RecordedRequest(nil), s.requests...)
+}
+
+// Reset approves payments again, declines with insufficient_funds, lets requests through and forgets the recorded requests.
+// The stored payments are kept.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outcome = OutcomeApprove
+	s.declineReason = api.DeclineReasonInsufficientFunds
+	s.status = 0
+	s.delay = 0
+	s.requests = nil
+}
+
+// intercept records the requests and applies the forced failures and delays
+func (s *Server) intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		s.mu.Lock()
+		s.requests = append(s.requests, RecordedRequest{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.RawQuery,
+			Header: r.Header.Clone(),
+			Body:   body,
+		})
+		status, delay := s.status, s.delay
+		s.mu.Unlock()
+
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if status != 0 {
+			code := api.ErrorCodeInvalidRequest
+			if status >= http.StatusInternalServerError {
+				code = api.ErrorCodeInternal
+			}
+			endpoint.WriteProblem(w, r, status, code, "apitest: forced failure")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// provider charges payments by the outcome chosen on the server
+type provider struct {
+	s *Server
+}
+
+func (p provider) Charge(ctx context.Context, payment service.Payment) (service.ChargeResult, error) {
+	p.s.mu.Lock()
+	outcome, declineReason := p.s.outcome, p.s.declineReason
+	p.s.mu.Unlock()
+
+	reference := "apitest_" + uuid.NewString()
+	switch outcome {
+	case OutcomeDecline:
+		return service.ChargeResult{
+			Status:        service.PaymentStatusFailed,
+			Reference:     reference,
+			DeclineCode:   string(declineReason),
+			DeclineReason: service.DeclineReason(declineReason),
+		}, nil
+	case OutcomeTimeout:
+		select {
+		case <-time.After(ProviderTimeout):
+			return service.ChargeResult{}, ErrProviderTimeout
+		case <-ctx.Done():
+			return service.ChargeResult{}, ctx.Err()
+		}
+	default:
+		return service.ChargeResult{Status: service.PaymentStatusPaid, Reference: reference}, nil
+	}
+}
+
+// Authorize holds the funds of the payments with manual capture by the same outcome as Charge
+func (p provider) Authorize(ctx context.Context, payment service.Payment) (service.ChargeResult, error) {
+	result, err := p.Charge(ctx, payment)
+	if result.Status == service.PaymentStatusPaid {
+		result.Status = service.PaymentStatusAuthorized
+	}
+	return result, err
+}
+
+func (p provider) Capture(ctx context.Context, payment service.Payment, amount money.Money) error {
+	return nil
+}
+
+func (p provider) Void(ctx context.Context, payment service.Payment) error {
+	return nil
+}
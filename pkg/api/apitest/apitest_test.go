@@ -0,0 +1,100 @@
+package apitest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/api"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/api/apitest"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/money"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// newPayment returns a payment of 19.90 BRL for a new order
+func newPayment(captureMode api.CaptureMode) api.Payment {
+	return api.Payment{
+		ID:          uuid.New(),
+		OrderID:     uuid.New(),
+		Amount:      money.Money{Amount: decimal.RequireFromString("19.90"), Currency: "BRL"},
+		CaptureMode: captureMode,
+	}
+}
+
+func TestServerOutcomes(t *testing.T) {
+	tests := []struct {
+		name        string
+		outcome     apitest.Outcome
+		reason      api.DeclineReason
+		captureMode api.CaptureMode
+		wantStatus  api.PaymentStatus
+		wantError   string
+	}{
+		{name: "approve", outcome: apitest.OutcomeApprove, wantStatus: api.PaymentStatusPaid},
+		{name: "approve an authorization", outcome: apitest.OutcomeApprove, captureMode: api.CaptureModeManual, wantStatus: api.PaymentStatusAuthorized},
+		{name: "decline", outcome: apitest.OutcomeDecline, wantStatus: api.PaymentStatusFailed, wantError: string(api.DeclineReasonInsufficientFunds)},
+		{name: "decline with a reason", outcome: apitest.OutcomeDecline, reason: api.DeclineReasonFraudSuspected, wantStatus: api.PaymentStatusFailed, wantError: string(api.DeclineReasonFraudSuspected)},
+		{name: "timeout", outcome: apitest.OutcomeTimeout, wantStatus: api.PaymentStatusFailed, wantError: string(api.DeclineReasonTimeout)},
+	}
+	srv := apitest.NewServer()
+	defer srv.Close()
+	client := srv.Client()
+	ctx := context.Background()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Reset()
+			srv.SetOutcome(tt.outcome)
+			if tt.reason != "" {
+				srv.SetDeclineReason(tt.reason)
+			}
+
+			created, err := client.CreatePayment(ctx, api.CreatePaymentRequest{Payment: newPayment(tt.captureMode), Wait: 5 * time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created.Status != tt.wantStatus || created.PaymentError != tt.wantError {
+				t.Errorf("payment is %s (%q), want %s (%q)", created.Status, created.PaymentError, tt.wantStatus, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestServerRecordsAndFailsRequests(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	client := srv.Client()
+	ctx := context.Background()
+
+	srv.FailRequests(http.StatusServiceUnavailable)
+	_, err := client.GetPayment(ctx, api.GetPaymentRequest{PaymentID: uuid.New()})
+	if apiErr, ok := err.(*api.APIError); !ok || apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.Code != api.ErrorCodeInternal {
+		t.Fatalf("err = %v, want a forced 503", err)
+	}
+	requests := srv.Requests()
+	if len(requests) != 1 || requests[0].Method != http.MethodGet {
+		t.Fatalf("requests = %+v, want the GET", requests)
+	}
+
+	// Reset lets the requests through and forgets them, the payments are kept
+	srv.Reset()
+	payment := newPayment("")
+	if _, err := client.CreatePayment(ctx, api.CreatePaymentRequest{Payment: payment}); err != nil {
+		t.Fatal(err)
+	}
+	srv.Reset()
+	if len(srv.Requests()) != 0 {
+		t.Errorf("requests kept after Reset: %+v", srv.Requests())
+	}
+
+	// the legacy client reads the payment with its decimal price
+	got, err := srv.LegacyClient().GetPayment(api.GetPaymentRequest{PaymentID: payment.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Payment.ID != payment.ID || !got.Payment.Price.Equal(payment.Amount.Amount) || got.Payment.Amount.Currency != "BRL" {
+		t.Errorf("legacy client read %+v, want payment %s of 19.90 BRL", got.Payment, payment.ID)
+	}
+}
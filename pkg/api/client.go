@@ -15,7 +15,7 @@ type client struct {
 	logger  kitlog.Logger
 }
 
-//go:generate mockgen -destination=../mocks/api_mocks.go -package=mocks github.com/SOAT1StackGoLang/msvc-payments/pkg/api PaymentAPI
+//go:generate mockgen -destination=../mocks/api_mocks.go -package=mocks github.com/SOAT1StackGoLang/msvc-payments/pkg/api PaymentAPI,PaymentAPIV2
 type PaymentAPI interface {
 	CreatePayment(request CreatePaymentRequest) (CreatePaymentResponse, error)
 	GetPayment(request GetPaymentRequest) (GetPaymentResponse, error)
@@ -0,0 +1,387 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+)
+
+// PaymentAPIV2 covers every endpoint of the service. Methods take a context, and when the
+// server answers with an error status they return an *APIError carrying the status and the body.
+type PaymentAPIV2 interface {
+	CreatePayment(ctx context.Context, request CreatePaymentRequest) (CreatePaymentResponse, error)
+	GetPayment(ctx context.Context, request GetPaymentRequest) (GetPaymentResponse, error)
+	UpdatePayment(ctx context.Context, request UpdatePaymentRequest) (UpdatePaymentResponse, error)
+	CreatePaymentAttempt(ctx context.Context, request CreatePaymentAttemptRequest) (CreatePaymentAttemptResponse, error)
+	CapturePayment(ctx context.Context, request CapturePaymentRequest) (CapturePaymentResponse, error)
+	VoidPayment(ctx context.Context, request VoidPaymentRequest) (VoidPaymentResponse, error)
+	GetPaymentsReport(ctx context.Context) (GetPaymentsReportResponse, error)
+	ListQuarantinedMessages(ctx context.Context) (ListQuarantinedMessagesResponse, error)
+	ReplayQuarantinedMessage(ctx context.Context, request ReplayQuarantinedMessageRequest) (ReplayQuarantinedMessageResponse, error)
+	DiscardQuarantinedMessage(ctx context.Context, request DiscardQuarantinedMessageRequest) (DiscardQuarantinedMessageResponse, error)
+}
+
+// APIError is returned when the server answers with an error status
+type APIError struct {
+	StatusCode int
+	// Code is the stable error code sent by the server, empty when it sent none
+	Code    string
+	Message string
+	// Fields lists the invalid fields of validation_failed errors
+	Fields []FieldError
+	// Body is the raw response body
+	Body []byte
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("payments api: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("payments api: %d: %s", e.StatusCode, e.Message)
+}
+
+// RetryPolicy controls how idempotent calls are retried on network errors and on 429, 502, 503 and 504.
+// The wait between attempts doubles from InitialBackoff up to MaxBackoff, with jitter.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt, 1 disables retries
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy is used when no policy is given
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 2 * time.Second}
+
+// DefaultTimeout is the timeout of the HTTP client created when none is given.
+// Calls with a Wait are allowed their wait on top of the timeout of the client.
+const DefaultTimeout = 10 * time.Second
+
+type clientV2 struct {
+	baseURL    string
+	httpClient *http.Client
+	retry      RetryPolicy
+	logger     kitlog.Logger
+}
+
+// ClientOption customizes the client created by NewClientV2
+type ClientOption func(*clientV2)
+
+// WithHTTPClient replaces the HTTP client, which otherwise has a DefaultTimeout timeout
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *clientV2) { c.httpClient = httpClient }
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *clientV2) { c.retry = policy }
+}
+
+// WithLogger logs the retried calls
+func WithLogger(logger kitlog.Logger) ClientOption {
+	return func(c *clientV2) { c.logger = logger }
+}
+
+// NewClientV2 creates a client of the service at baseURL, http:// is assumed when no scheme is given
+func NewClientV2(baseURL string, opts ...ClientOption) PaymentAPIV2 {
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		baseURL = "http://" + baseURL
+	}
+	c := &clientV2{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		retry:      DefaultRetryPolicy,
+		logger:     kitlog.NewNopLogger(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *clientV2) CreatePayment(ctx context.Context, request CreatePaymentRequest) (CreatePaymentResponse, error) {
+	var response CreatePaymentResponse
+	err := c.do(ctx, call{method: http.MethodPost, path: "/payments" + waitQuery("", request.Wait), in: request, out: &response, wait: request.Wait})
+	return response, err
+}
+
+func (c *clientV2) GetPayment(ctx context.Context, request GetPaymentRequest) (GetPaymentResponse, error) {
+	var response GetPaymentResponse
+	err := c.do(ctx, call{method: http.MethodGet, path: "/payments/" + request.PaymentID.String() + waitQuery(request.WaitFor, request.Wait), out: &response, wait: request.Wait})
+	return response, err
+}
+
+func (c *clientV2) UpdatePayment(ctx context.Context, request UpdatePaymentRequest) (UpdatePaymentResponse, error) {
+	var response UpdatePaymentResponse
+	err := c.do(ctx, call{method: http.MethodPut, path: "/payments", header: ifMatchHeader(request.IfMatch), in: request, out: &response})
+	return response, err
+}
+
+// CreatePaymentAttempt retries a failed payment, it is charged again in the background
+func (c *clientV2) CreatePaymentAttempt(ctx context.Context, request CreatePaymentAttemptRequest) (CreatePaymentAttemptResponse, error) {
+	var response CreatePaymentAttemptResponse
+	err := c.do(ctx, call{method: http.MethodPost, path: "/payments/" + request.PaymentID.String() + "/attempts", header: ifMatchHeader(request.IfMatch), out: &response})
+	return response, err
+}
+
+// CapturePayment charges an authorized payment, all of its price unless Amount is set
+func (c *clientV2) CapturePayment(ctx context.Context, request CapturePaymentRequest) (CapturePaymentResponse, error) {
+	var response CapturePaymentResponse
+	err := c.do(ctx, call{method: http.MethodPost, path: "/payments/" + request.PaymentID.String() + "/capture", header: ifMatchHeader(request.IfMatch), in: request, out: &response})
+	return response, err
+}
+
+// VoidPayment releases the funds held by an authorized payment. It is retried like the reads: a void
+// whose response was lost fails again with invalid_transition, or version_conflict with IfMatch.
+func (c *clientV2) VoidPayment(ctx context.Context, request VoidPaymentRequest) (VoidPaymentResponse, error) {
+	var response VoidPaymentResponse
+	err := c.do(ctx, call{
+		method:     http.MethodPost,
+		path:       "/payments/" + request.PaymentID.String() + "/void",
+		header:     ifMatchHeader(request.IfMatch),
+		out:        &response,
+		idempotent: true,
+	})
+	return response, err
+}
+
+// GetPaymentsReport sums the payments by currency and status, the report takes no parameters
+func (c *clientV2) GetPaymentsReport(ctx context.Context) (GetPaymentsReportResponse, error) {
+	var response GetPaymentsReportResponse
+	err := c.do(ctx, call{method: http.MethodGet, path: "/reports/payments", out: &response})
+	return response, err
+}
+
+func (c *clientV2) ListQuarantinedMessages(ctx context.Context) (ListQuarantinedMessagesResponse, error) {
+	var response ListQuarantinedMessagesResponse
+	err := c.do(ctx, call{method: http.MethodGet, path: "/quarantine", out: &response})
+	return response, err
+}
+
+// ReplayQuarantinedMessage returns the response without error when the message was quarantined again,
+// with the new entry in Quarantined
+func (c *clientV2) ReplayQuarantinedMessage(ctx context.Context, request ReplayQuarantinedMessageRequest) (ReplayQuarantinedMessageResponse, error) {
+	var response ReplayQuarantinedMessageResponse
+	err := c.do(ctx, call{method: http.MethodPost, path: "/quarantine/" + request.ID.String() + "/replay", in: request, out: &response})
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnprocessableEntity {
+		if json.Unmarshal(apiErr.Body, &response) == nil && response.Quarantined != nil {
+			return response, nil
+		}
+	}
+	return response, err
+}
+
+func (c *clientV2) DiscardQuarantinedMessage(ctx context.Context, request DiscardQuarantinedMessageRequest) (DiscardQuarantinedMessageResponse, error) {
+	var response DiscardQuarantinedMessageResponse
+	err := c.do(ctx, call{method: http.MethodDelete, path: "/quarantine/" + request.ID.String(), out: &response})
+	return response, err
+}
+
+// ifMatchHeader sends the expected version as If-Match, no header is sent when it is nil
+func ifMatchHeader(version *int64) http.Header {
+	header := http.Header{}
+	if version != nil {
+		header.Set("If-Match", strconv.Quote(strconv.FormatInt(*version, 10)))
+	}
+	return header
+}
+
+// waitQuery encodes the wait_for and wait query parameters, empty when neither is set
+func waitQuery(waitFor string, wait time.Duration) string {
+	query := url.Values{}
+	if waitFor != "" {
+		query.Set("wait_for", waitFor)
+	}
+	if wait > 0 {
+		query.Set("wait", wait.String())
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+// call is a request to the service, its response is decoded into out
+type call struct {
+	method  string
+	path    string
+	header  http.Header
+	in, out interface{}
+	// wait is how long the server may hold a long-poll before answering
+	wait time.Duration
+	// idempotent marks a POST that may be sent again, such as a void
+	idempotent bool
+}
+
+// retryable reports whether the call can be sent again without applying it twice: reads, deletes,
+// updates guarded by If-Match and the calls marked idempotent
+func (c call) retryable() bool {
+	switch c.method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return true
+	case http.MethodPut:
+		return c.header.Get("If-Match") != ""
+	}
+	return c.idempotent
+}
+
+// do sends the call, retrying the retryable ones by the retry policy, and decodes a 2xx response into out.
+// A long-poll gets its wait on top of the client timeout, and is not retried once it timed out
+// since the server already held it for the whole wait.
+func (c *clientV2) do(ctx context.Context, call call) error {
+	var payload []byte
+	if call.in != nil {
+		var err error
+		payload, err = json.Marshal(call.in)
+		if err != nil {
+			return err
+		}
+	}
+
+	httpClient := c.httpClient
+	if call.wait > 0 {
+		timeout := httpClient.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, call.wait+timeout)
+		defer cancel()
+
+		// the deadline of the context replaces the timeout of the client, which may be shorter than the wait
+		longPoll := *httpClient
+		longPoll.Timeout = 0
+		httpClient = &longPoll
+	}
+
+	attempts := 1
+	if call.retryable() && c.retry.MaxAttempts > 1 {
+		attempts = c.retry.MaxAttempts
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var retryable bool
+		retryable, err = c.send(ctx, httpClient, call.method, call.path, call.header, payload, call.out)
+		if call.wait > 0 && timedOut(err) {
+			return err
+		}
+		if err == nil || !retryable || attempt >= attempts {
+			return err
+		}
+
+		wait := c.backoff(attempt)
+		_ = c.logger.Log("msg", "retrying payments api call", "method", call.method, "path", call.path, "attempt", attempt, "wait", wait, "err", err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// timedOut reports whether the call failed by running out of time, in the client or in a gateway
+func timedOut(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusGatewayTimeout
+	}
+	var netErr interface{ Timeout() bool }
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// send makes one attempt and reports whether a failure can be retried
+func (c *clientV2) send(ctx context.Context, httpClient *http.Client, method, path string, header http.Header, payload []byte, out interface{}) (bool, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return false, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// the caller gave up, retrying would fail the same way
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return true, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true, newAPIError(resp.StatusCode, respBody)
+		}
+		return false, newAPIError(resp.StatusCode, respBody)
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return false, nil
+	}
+	return false, json.Unmarshal(respBody, out)
+}
+
+// backoff returns the wait before the attempt following attempt, between half and all of the doubled backoff
+func (c *clientV2) backoff(attempt int) time.Duration {
+	wait := c.retry.InitialBackoff << (attempt - 1)
+	if wait <= 0 || (c.retry.MaxBackoff > 0 && wait > c.retry.MaxBackoff) {
+		wait = c.retry.MaxBackoff
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// newAPIError reads the error code and message of a JSON error body, such as {"code": "...", "detail": "..."},
+// and falls back to the body text
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, Body: body}
+
+	var problem struct {
+		Code   string       `json:"code"`
+		Title  string       `json:"title"`
+		Detail string       `json:"detail"`
+		Errors []FieldError `json:"errors"`
+	}
+	if json.Unmarshal(body, &problem) == nil {
+		apiErr.Code = problem.Code
+		apiErr.Fields = problem.Errors
+		apiErr.Message = problem.Detail
+		if apiErr.Message == "" {
+			apiErr.Message = problem.Title
+		}
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(statusCode)
+	}
+	return apiErr
+}
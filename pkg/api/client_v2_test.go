@@ -0,0 +1,175 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/api"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/api/apitest"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/money"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// clientWithTimeout returns a client of srv whose HTTP client times out after timeout
+func clientWithTimeout(srv *apitest.Server, timeout time.Duration, policy api.RetryPolicy) api.PaymentAPIV2 {
+	return srv.Client(api.WithHTTPClient(&http.Client{Timeout: timeout}), api.WithRetryPolicy(policy))
+}
+
+func TestClientV2LongPollOutlivesTheClientTimeout(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	srv.DelayResponses(200 * time.Millisecond)
+	client := clientWithTimeout(srv, 50*time.Millisecond, api.RetryPolicy{MaxAttempts: 1})
+	ctx := context.Background()
+
+	// the server answers, with a 404 for the unknown payment, after the timeout of the client
+	_, err := client.GetPayment(ctx, api.GetPaymentRequest{PaymentID: uuid.New(), WaitFor: api.WaitForFinal, Wait: time.Second})
+	var apiErr *api.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("long-poll err = %v, want a 404", err)
+	}
+
+	_, err = client.GetPayment(ctx, api.GetPaymentRequest{PaymentID: uuid.New()})
+	if errors.As(err, &apiErr) || err == nil {
+		t.Fatalf("call without wait err = %v, want a timeout", err)
+	}
+}
+
+func TestClientV2DoesNotRetryTimedOutLongPoll(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	srv.DelayResponses(time.Second)
+	client := clientWithTimeout(srv, 50*time.Millisecond, api.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+	_, err := client.GetPayment(context.Background(), api.GetPaymentRequest{PaymentID: uuid.New(), WaitFor: api.WaitForFinal, Wait: 50 * time.Millisecond})
+	if err == nil {
+		t.Fatal("long-poll did not time out")
+	}
+	if n := len(srv.Requests()); n != 1 {
+		t.Errorf("long-poll sent %d times, want once", n)
+	}
+}
+
+func TestClientV2RetriesOnlyIdempotentCalls(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	srv.FailRequests(http.StatusServiceUnavailable)
+	client := srv.Client(api.WithRetryPolicy(api.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+	ctx := context.Background()
+	version := int64(1)
+
+	tests := []struct {
+		name     string
+		call     func() error
+		wantSent int
+	}{
+		{name: "get", wantSent: 3, call: func() error {
+			_, err := client.GetPayment(ctx, api.GetPaymentRequest{PaymentID: uuid.New()})
+			return err
+		}},
+		{name: "report", wantSent: 3, call: func() error {
+			_, err := client.GetPaymentsReport(ctx)
+			return err
+		}},
+		{name: "delete", wantSent: 3, call: func() error {
+			_, err := client.DiscardQuarantinedMessage(ctx, api.DiscardQuarantinedMessageRequest{ID: uuid.New()})
+			return err
+		}},
+		{name: "void", wantSent: 3, call: func() error {
+			_, err := client.VoidPayment(ctx, api.VoidPaymentRequest{PaymentID: uuid.New()})
+			return err
+		}},
+		{name: "update with If-Match", wantSent: 3, call: func() error {
+			_, err := client.UpdatePayment(ctx, api.UpdatePaymentRequest{PaymentID: uuid.New(), IfMatch: &version})
+			return err
+		}},
+		{name: "update without If-Match", wantSent: 1, call: func() error {
+			_, err := client.UpdatePayment(ctx, api.UpdatePaymentRequest{PaymentID: uuid.New()})
+			return err
+		}},
+		{name: "capture", wantSent: 1, call: func() error {
+			_, err := client.CapturePayment(ctx, api.CapturePaymentRequest{PaymentID: uuid.New(), IfMatch: &version})
+			return err
+		}},
+		{name: "create", wantSent: 1, call: func() error {
+			_, err := client.CreatePayment(ctx, api.CreatePaymentRequest{})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(srv.Requests())
+			if err := tt.call(); err == nil {
+				t.Fatal("call succeeded on a failing server")
+			}
+			if sent := len(srv.Requests()) - before; sent != tt.wantSent {
+				t.Errorf("sent %d times, want %d", sent, tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestClientV2StaleIfMatchIsAPreconditionFailure(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	client := srv.Client()
+	ctx := context.Background()
+
+	price, err := money.New(decimal.RequireFromString("19.90"), "BRL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment := api.Payment{ID: uuid.New(), OrderID: uuid.New(), Amount: price}
+	created, err := client.CreatePayment(ctx, api.CreatePaymentRequest{Payment: payment, Wait: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Status != api.PaymentStatusPaid {
+		t.Fatalf("created payment is %s, want paid", created.Status)
+	}
+
+	stale := int64(1)
+	_, err = client.UpdatePayment(ctx, api.UpdatePaymentRequest{PaymentID: payment.ID, PaymentStatus: api.PaymentStatusClosed, IfMatch: &stale})
+	var apiErr *api.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusPreconditionFailed || apiErr.Code != api.ErrorCodeVersionConflict {
+		t.Fatalf("update at a stale version: err = %v, want 412 %s", err, api.ErrorCodeVersionConflict)
+	}
+
+	got, err := client.GetPayment(ctx, api.GetPaymentRequest{PaymentID: payment.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := got.Payment.Version
+	updated, err := client.UpdatePayment(ctx, api.UpdatePaymentRequest{PaymentID: payment.ID, PaymentStatus: api.PaymentStatusClosed, IfMatch: &current})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status != api.PaymentStatusClosed || updated.Version != current+1 {
+		t.Errorf("updated payment is %s at version %d, want closed at version %d", updated.Status, updated.Version, current+1)
+	}
+}
+
+func TestClientV2ReadsProblems(t *testing.T) {
+	srv := apitest.NewServer()
+	defer srv.Close()
+	client := srv.Client()
+	ctx := context.Background()
+
+	_, err := client.GetPayment(ctx, api.GetPaymentRequest{PaymentID: uuid.New()})
+	var apiErr *api.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != api.ErrorCodePaymentNotFound {
+		t.Fatalf("get a missing payment: err = %v, want 404 %s", err, api.ErrorCodePaymentNotFound)
+	}
+
+	_, err = client.CreatePayment(ctx, api.CreatePaymentRequest{Payment: api.Payment{ID: uuid.New()}})
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity || apiErr.Code != api.ErrorCodeValidationFailed {
+		t.Fatalf("create an invalid payment: err = %v, want 422 %s", err, api.ErrorCodeValidationFailed)
+	}
+	if len(apiErr.Fields) == 0 {
+		t.Errorf("the invalid fields are not listed: %+v", apiErr)
+	}
+}
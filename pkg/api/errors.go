@@ -0,0 +1,24 @@
+package api
+
+// FieldError describes an invalid field of a request rejected with ErrorCodeValidationFailed
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error codes sent by the service in the code field of its problem+json responses,
+// found in APIError.Code. They are stable, clients can branch on them.
+const (
+	ErrorCodeInvalidRequest             = "invalid_request"
+	ErrorCodeValidationFailed           = "validation_failed"
+	ErrorCodePaymentNotFound            = "payment_not_found"
+	ErrorCodePaymentAlreadyExists       = "payment_already_exists"
+	ErrorCodeOrderHasActivePayment      = "order_has_active_payment"
+	ErrorCodeInvalidTransition          = "invalid_transition"
+	ErrorCodeCaptureNotSupported        = "capture_not_supported"
+	ErrorCodeVersionConflict            = "version_conflict"
+	ErrorCodeQuarantinedMessageNotFound = "quarantined_message_not_found"
+	ErrorCodeNotFound                   = "not_found"
+	ErrorCodeMethodNotAllowed           = "method_not_allowed"
+	ErrorCodeInternal                   = "internal_error"
+)
@@ -1,18 +1,81 @@
 package api
 
 import (
+	"encoding/json"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/money"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"time"
 )
 
+// Payment is the payment as exchanged with the service.
+// The price is sent as the Price object of Amount, with a decimal string such as "19.90" so it is never
+// rounded through a float. Bare amounts ("19.90" or 19.90) are still accepted when decoding and are read as BRL.
 type Payment struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	Price     decimal.Decimal
-	OrderID   uuid.UUID
-	Status    PaymentStatus
+	// Deprecated: Price is the amount of the price, sent in BRL when Amount is zero. Use Amount, which
+	// carries the currency. Decoded payments have both set.
+	Price   decimal.Decimal
+	OrderID uuid.UUID
+	Status  PaymentStatus
+	Version int64
+	// Attempts are the charges made at the provider, oldest first
+	Attempts []Attempt `json:",omitempty"`
+	// DeclineReason is the reason of the last failed attempt, cleared when the payment is retried
+	DeclineReason DeclineReason `json:",omitempty"`
+	// ExpiresAt is when a pending payment expires, nil when pending payments don't expire
+	ExpiresAt *time.Time `json:",omitempty"`
+	// CaptureMode is empty for automatic capture
+	CaptureMode CaptureMode `json:",omitempty"`
+	// AuthorizationExpiresAt is when an authorized payment is voided unless it is captured
+	AuthorizationExpiresAt *time.Time `json:",omitempty"`
+	// CapturedAmount is the amount charged by the capture, at most the price
+	CapturedAmount *money.Money `json:",omitempty"`
+	// Amount is the price with its currency, exchanged as Price
+	Amount money.Money `json:"-"`
+}
+
+// paymentFields has the fields of Payment without its JSON methods
+type paymentFields Payment
+
+// MarshalJSON sends Amount as the Price, or the deprecated Price in BRL when Amount is zero
+func (p Payment) MarshalJSON() ([]byte, error) {
+	price := p.Amount
+	if price.Currency == "" && price.Amount.IsZero() {
+		price = money.Money{Amount: p.Price, Currency: money.DefaultCurrency}
+	}
+	return json.Marshal(struct {
+		paymentFields
+		Price money.Money
+	}{paymentFields(p), price})
+}
+
+// UnmarshalJSON reads the Price into Amount, and its amount into the deprecated Price
+func (p *Payment) UnmarshalJSON(data []byte) error {
+	decoded := struct {
+		*paymentFields
+		Price money.Money
+	}{paymentFields: (*paymentFields)(p)}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	p.Amount, p.Price = decoded.Price, decoded.Price.Amount
+	return nil
+}
+
+// Attempt is a charge of the payment at the provider
+type Attempt struct {
+	Number            int
+	ProviderReference string `json:",omitempty"`
+	// Outcome is paid, authorized or failed
+	Outcome PaymentStatus
+	// DeclineCode is the code given by the provider, DeclineReason its normalized reason
+	DeclineCode   string        `json:",omitempty"`
+	DeclineReason DeclineReason `json:",omitempty"`
+	StartedAt     time.Time
+	FinishedAt    time.Time
 }
 
 type PaymentStatus string
@@ -21,31 +84,117 @@ const (
 	PaymentStatusPaid    PaymentStatus = "paid"
 	PaymentStatusPending PaymentStatus = "pending"
 	PaymentStatusFailed  PaymentStatus = "failed"
-	// TO-DO PaymentStatusClosed  PaymentStatus = "closed"
+	PaymentStatusClosed  PaymentStatus = "closed"
+	// PaymentStatusProcessing holds a pending payment while the provider charges or authorizes it
+	PaymentStatusProcessing PaymentStatus = "processing"
+	// PaymentStatusAuthorized holds the funds of a payment with manual capture until it is captured or voided
+	PaymentStatusAuthorized PaymentStatus = "authorized"
+	PaymentStatusCaptured   PaymentStatus = "captured"
+	PaymentStatusVoided     PaymentStatus = "voided"
+	// PaymentStatusCapturing and PaymentStatusVoiding hold an authorized payment while the provider captures or voids it
+	PaymentStatusCapturing PaymentStatus = "capturing"
+	PaymentStatusVoiding   PaymentStatus = "voiding"
+	// PaymentStatusExpired is reached by pending payments that were not charged in time
+	PaymentStatusExpired PaymentStatus = "expired"
 )
 
+// CaptureMode tells whether a payment is charged at once or authorized and captured later
+type CaptureMode string
+
+const (
+	CaptureModeAutomatic CaptureMode = "automatic"
+	CaptureModeManual    CaptureMode = "manual"
+)
+
+// DeclineReason is the normalized reason a payment failed, also sent as PaymentError
+type DeclineReason string
+
+const (
+	DeclineReasonInsufficientFunds DeclineReason = "insufficient_funds"
+	DeclineReasonExpired           DeclineReason = "expired"
+	DeclineReasonFraudSuspected    DeclineReason = "fraud_suspected"
+	DeclineReasonTimeout           DeclineReason = "timeout"
+	DeclineReasonProviderError     DeclineReason = "provider_error"
+)
+
+// WaitForFinal waits for the payment to leave pending
+const WaitForFinal = "final"
+
 type CreatePaymentRequest struct {
 	Payment Payment `json:"payment"`
+	// Wait holds the response until the payment is final or Wait elapses, sent as the wait query parameter.
+	// The v2 client allows the wait on top of its timeout.
+	Wait time.Duration `json:"-"`
 }
 
 type CreatePaymentResponse struct {
 	PaymentID uuid.UUID     `json:"payment_id"`
 	Status    PaymentStatus `json:"status"`
+	// PaymentError is the decline reason of a payment that failed while the request waited
+	PaymentError string `json:"payment_error,omitempty"`
 }
 
 type UpdatePaymentRequest struct {
 	PaymentID     uuid.UUID     `json:"payment_id"`
 	PaymentStatus PaymentStatus `json:"payment_status"`
+	// IfMatch is sent as the If-Match header, the update fails with 412 if the payment is no longer at this version
+	IfMatch *int64 `json:"-"`
 }
 
 type UpdatePaymentResponse struct {
 	PaymentID    uuid.UUID     `json:"payment_id"`
 	Status       PaymentStatus `json:"status"`
 	PaymentError string        `json:"payment_error,omitempty"`
+	Version      int64         `json:"version"`
+}
+
+type CreatePaymentAttemptRequest struct {
+	PaymentID uuid.UUID `json:"-"`
+	// IfMatch is sent as the If-Match header, the retry fails with 412 if the payment is no longer at this version
+	IfMatch *int64 `json:"-"`
+}
+
+type CreatePaymentAttemptResponse struct {
+	PaymentID uuid.UUID     `json:"payment_id"`
+	Status    PaymentStatus `json:"status"`
+	// Attempts is the number of attempts made before this one
+	Attempts int   `json:"attempts"`
+	Version  int64 `json:"version"`
+}
+
+type CapturePaymentRequest struct {
+	PaymentID uuid.UUID `json:"-"`
+	// Amount captures part of the authorized price, the whole price is captured when it is nil
+	Amount *money.Money `json:"amount,omitempty"`
+	// IfMatch is sent as the If-Match header, the capture fails with 412 if the payment is no longer at this version
+	IfMatch *int64 `json:"-"`
+}
+
+type CapturePaymentResponse struct {
+	PaymentID      uuid.UUID     `json:"payment_id"`
+	Status         PaymentStatus `json:"status"`
+	CapturedAmount money.Money   `json:"captured_amount"`
+	Version        int64         `json:"version"`
+}
+
+type VoidPaymentRequest struct {
+	PaymentID uuid.UUID `json:"-"`
+	// IfMatch is sent as the If-Match header, the void fails with 412 if the payment is no longer at this version
+	IfMatch *int64 `json:"-"`
+}
+
+type VoidPaymentResponse struct {
+	PaymentID uuid.UUID     `json:"payment_id"`
+	Status    PaymentStatus `json:"status"`
+	Version   int64         `json:"version"`
 }
 
 type GetPaymentRequest struct {
 	PaymentID uuid.UUID `json:"payment_id"`
+	// WaitFor is WaitForFinal to hold the response until the payment is final, for at most Wait
+	// or the maximum of the server. The v2 client allows the wait on top of its timeout.
+	WaitFor string        `json:"-"`
+	Wait    time.Duration `json:"-"`
 }
 
 type GetPaymentResponse struct {
@@ -53,3 +202,54 @@ type GetPaymentResponse struct {
 	Status       PaymentStatus `json:"status"`
 	PaymentError string        `json:"payment_error,omitempty"`
 }
+
+type GetPaymentsReportResponse struct {
+	Currencies []CurrencyReport `json:"currencies"`
+}
+
+// CurrencyReport sums the payments of one currency. Count counts the payments of every status,
+// Total only sums the amounts charged by the paid and captured payments.
+type CurrencyReport struct {
+	Currency string                         `json:"currency"`
+	Count    int                            `json:"count"`
+	Total    money.Money                    `json:"total"`
+	ByStatus map[PaymentStatus]StatusTotals `json:"by_status"`
+}
+
+// StatusTotals sums the payments of one currency in one status
+type StatusTotals struct {
+	Count int         `json:"count"`
+	Total money.Money `json:"total"`
+}
+
+// QuarantinedMessage is an incoming message the service could not apply
+type QuarantinedMessage struct {
+	ID         uuid.UUID `json:"id"`
+	ReceivedAt time.Time `json:"received_at"`
+	Error      string    `json:"error"`
+	Payload    string    `json:"payload"`
+}
+
+type ListQuarantinedMessagesResponse struct {
+	Messages []QuarantinedMessage `json:"messages"`
+}
+
+type ReplayQuarantinedMessageRequest struct {
+	ID uuid.UUID `json:"-"`
+	// Payload replaces the quarantined payload when set
+	Payload *string `json:"payload,omitempty"`
+}
+
+type ReplayQuarantinedMessageResponse struct {
+	ID uuid.UUID `json:"id"`
+	// Quarantined is set when the message still could not be applied
+	Quarantined *QuarantinedMessage `json:"quarantined,omitempty"`
+}
+
+type DiscardQuarantinedMessageRequest struct {
+	ID uuid.UUID `json:"-"`
+}
+
+type DiscardQuarantinedMessageResponse struct {
+	ID uuid.UUID `json:"id"`
+}
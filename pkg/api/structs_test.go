@@ -0,0 +1,68 @@
+package api_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/api"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+func TestPaymentPriceJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		payment api.Payment
+		want    string
+	}{
+		{
+			name:    "deprecated price is sent in BRL",
+			payment: api.Payment{Price: decimal.RequireFromString("19.90")},
+			want:    `{"amount":"19.90","currency":"BRL"}`,
+		},
+		{
+			name:    "amount carries its currency",
+			payment: api.Payment{Amount: money.Money{Amount: decimal.NewFromInt(1235), Currency: "JPY"}},
+			want:    `{"amount":"1235","currency":"JPY"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := json.Marshal(tt.payment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(encoded, &fields); err != nil {
+				t.Fatal(err)
+			}
+			if string(fields["Price"]) != tt.want {
+				t.Errorf("Price = %s, want %s", fields["Price"], tt.want)
+			}
+			if _, ok := fields["Amount"]; ok {
+				t.Errorf("Amount is sent: %s", encoded)
+			}
+
+			var decoded api.Payment
+			if err := json.Unmarshal(encoded, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			var want money.Money
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !decoded.Amount.Amount.Equal(want.Amount) || decoded.Amount.Currency != want.Currency || !decoded.Price.Equal(want.Amount) {
+				t.Errorf("decoded Amount %s and Price %s, want %s", decoded.Amount, decoded.Price, want)
+			}
+		})
+	}
+
+	// payments stored before prices had a currency
+	var bare api.Payment
+	if err := json.Unmarshal([]byte(`{"Price":"19.90","Status":"paid"}`), &bare); err != nil {
+		t.Fatal(err)
+	}
+	if bare.Amount.Currency != "BRL" || !bare.Price.Equal(decimal.RequireFromString("19.90")) || bare.Status != api.PaymentStatusPaid {
+		t.Errorf("decoded %+v, want 19.90 BRL paid", bare)
+	}
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/messages"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/money"
+	kitlog "github.com/go-kit/log"
+	"github.com/google/uuid"
+)
+
+// PaymentStatusChanged is a status change published by the service, decoded from any of its event formats
+type PaymentStatusChanged struct {
+	PaymentID uuid.UUID
+	OrderID   uuid.UUID
+	Status    PaymentStatus
+	UpdatedAt time.Time
+	// Price is nil for events published before it was added
+	Price *money.Money
+	// DeclineReason is set when the payment failed
+	DeclineReason DeclineReason
+	// CapturedAmount is set when the payment was captured
+	CapturedAmount *money.Money
+	// EventID and CorrelationID are empty for events published in the bare format
+	EventID       string
+	CorrelationID string
+}
+
+// PaymentStatusHandler handles a status change, returned errors are logged and the subscription goes on
+type PaymentStatusHandler func(ctx context.Context, event PaymentStatusChanged) error
+
+// StatusSubscriber delivers the status changes published on messages.PaymentStatusResponseChannel
+type StatusSubscriber struct {
+	bus      datastore.MessageBus
+	orderIDs map[uuid.UUID]bool
+	statuses map[PaymentStatus]bool
+	// minBackoff and maxBackoff bound the wait before subscribing again after the subscription is lost
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	logger     kitlog.Logger
+	closer     func() error
+}
+
+// SubscriberOption customizes the subscriber created by NewStatusSubscriber
+type SubscriberOption func(*StatusSubscriber)
+
+// WithOrderIDs delivers only the status changes of these orders
+func WithOrderIDs(orderIDs ...uuid.UUID) SubscriberOption {
+	return func(s *StatusSubscriber) {
+		for _, id := range orderIDs {
+			s.orderIDs[id] = true
+		}
+	}
+}
+
+// WithStatuses delivers only the changes to these statuses
+func WithStatuses(statuses ...PaymentStatus) SubscriberOption {
+	return func(s *StatusSubscriber) {
+		for _, status := range statuses {
+			s.statuses[status] = true
+		}
+	}
+}
+
+// WithReconnectBackoff bounds the wait before subscribing again, it doubles from min to max
+func WithReconnectBackoff(min, max time.Duration) SubscriberOption {
+	return func(s *StatusSubscriber) {
+		s.minBackoff = min
+		s.maxBackoff = max
+	}
+}
+
+// WithSubscriberLogger logs reconnects and the events that could not be decoded or handled
+func WithSubscriberLogger(logger kitlog.Logger) SubscriberOption {
+	return func(s *StatusSubscriber) { s.logger = logger }
+}
+
+// NewStatusSubscriber creates a subscriber reading from bus, such as the store returned by datastore.NewRedisStore
+func NewStatusSubscriber(bus datastore.MessageBus, opts ...SubscriberOption) *StatusSubscriber {
+	s := &StatusSubscriber{
+		bus:        bus,
+		orderIDs:   make(map[uuid.UUID]bool),
+		statuses:   make(map[PaymentStatus]bool),
+		minBackoff: 100 * time.Millisecond,
+		maxBackoff: 10 * time.Second,
+		logger:     kitlog.NewNopLogger(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// DialStatusSubscriber connects to the Redis of the service and creates a subscriber on it,
+// Close releases the connection
+func DialStatusSubscriber(redisOpts datastore.RedisOptions, opts ...SubscriberOption) (*StatusSubscriber, error) {
+	store, err := datastore.NewRedisStore(redisOpts)
+	if err != nil {
+		return nil, err
+	}
+	s := NewStatusSubscriber(store, opts...)
+	s.closer = store.CloseClient
+	return s, nil
+}
+
+// Close releases the connection opened by DialStatusSubscriber
+func (s *StatusSubscriber) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer()
+}
+
+// OnPaymentStatusChanged calls handler for every status change that passes the filters, one at a time.
+// It subscribes again whenever the subscription is lost and returns only when ctx is done.
+func (s *StatusSubscriber) OnPaymentStatusChanged(ctx context.Context, handler PaymentStatusHandler) error {
+	backoff := s.minBackoff
+	for {
+		sub, err := s.bus.Subscribe(ctx, messages.PaymentStatusResponseChannel)
+		if err == nil {
+			backoff = s.minBackoff
+			s.consume(ctx, sub, handler)
+		} else {
+			_ = s.logger.Log("msg", "subscribing to payment status changes", "err", err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		_ = s.logger.Log("msg", "payment status subscription lost, subscribing again", "wait", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, s.maxBackoff)
+	}
+}
+
+// consume delivers the messages of one subscription until it is closed
+func (s *StatusSubscriber) consume(ctx context.Context, sub <-chan *datastore.Message, handler PaymentStatusHandler) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-sub:
+			if !ok {
+				return
+			}
+			event, err := DecodePaymentStatusChanged([]byte(msg.Payload))
+			if err != nil {
+				_ = s.logger.Log("msg", "skipping payment status change", "err", err)
+				continue
+			}
+			if !s.matches(event) {
+				continue
+			}
+			if err := handler(ctx, event); err != nil {
+				_ = s.logger.Log("msg", "handling payment status change", "payment_id", event.PaymentID, "err", err)
+			}
+		}
+	}
+}
+
+func (s *StatusSubscriber) matches(event PaymentStatusChanged) bool {
+	if len(s.orderIDs) > 0 && !s.orderIDs[event.OrderID] {
+		return false
+	}
+	if len(s.statuses) > 0 && !s.statuses[event.Status] {
+		return false
+	}
+	return true
+}
+
+// DecodePaymentStatusChanged reads a status change in the bare, envelope or CloudEvents format
+func DecodePaymentStatusChanged(data []byte) (PaymentStatusChanged, error) {
+	envelope, err := messages.Decode(data, messages.TypePaymentStatusChanged)
+	if err != nil {
+		return PaymentStatusChanged{}, err
+	}
+	if envelope.Type != messages.TypePaymentStatusChanged && !strings.HasPrefix(envelope.Type, messages.PaymentEventTypePrefix) {
+		return PaymentStatusChanged{}, fmt.Errorf("unexpected message type %q", envelope.Type)
+	}
+
+	var msg messages.PaymentStatusChangedMessage
+	if err := envelope.DecodePayload(&msg); err != nil {
+		return PaymentStatusChanged{}, err
+	}
+
+	paymentID, err := uuid.Parse(msg.ID)
+	if err != nil {
+		return PaymentStatusChanged{}, fmt.Errorf("invalid payment id: %w", err)
+	}
+	orderID, err := uuid.Parse(msg.OrderID)
+	if err != nil {
+		return PaymentStatusChanged{}, fmt.Errorf("invalid order id: %w", err)
+	}
+	if msg.Status == "" {
+		return PaymentStatusChanged{}, errors.New("missing status")
+	}
+
+	event := PaymentStatusChanged{
+		PaymentID:      paymentID,
+		OrderID:        orderID,
+		Status:         PaymentStatus(msg.Status),
+		Price:          msg.Price,
+		DeclineReason:  DeclineReason(msg.DeclineReason),
+		CapturedAmount: msg.CapturedAmount,
+		EventID:        envelope.ID,
+		CorrelationID:  envelope.CorrelationID,
+	}
+	if updatedAt, err := time.Parse(time.RFC3339, msg.UpdatedAt); err == nil {
+		event.UpdatedAt = updatedAt
+	}
+	return event, nil
+}
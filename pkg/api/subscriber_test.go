@@ -0,0 +1,113 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/api"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore"
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/messages"
+	"github.com/google/uuid"
+)
+
+// flakyBus loses its first subscription at once and signals every subscription on subscribed
+type flakyBus struct {
+	datastore.RedisStore
+	subscriptions int
+	subscribed    chan struct{}
+}
+
+func (b *flakyBus) Subscribe(ctx context.Context, channel string) (<-chan *datastore.Message, error) {
+	b.subscriptions++
+	if b.subscriptions == 1 {
+		lost := make(chan *datastore.Message)
+		close(lost)
+		return lost, nil
+	}
+	sub, err := b.RedisStore.Subscribe(ctx, channel)
+	b.subscribed <- struct{}{}
+	return sub, err
+}
+
+func TestStatusSubscriber(t *testing.T) {
+	store := datastore.NewMemoryStore()
+	defer store.CloseClient()
+	bus := &flakyBus{RedisStore: store, subscribed: make(chan struct{}, 1)}
+	orderID, otherOrderID := uuid.New(), uuid.New()
+
+	sub := api.NewStatusSubscriber(bus,
+		api.WithOrderIDs(orderID),
+		api.WithStatuses(api.PaymentStatusPaid, api.PaymentStatusFailed),
+		api.WithReconnectBackoff(time.Millisecond, time.Millisecond),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan api.PaymentStatusChanged, 10)
+	done := make(chan error)
+	go func() {
+		done <- sub.OnPaymentStatusChanged(ctx, func(ctx context.Context, event api.PaymentStatusChanged) error {
+			events <- event
+			return nil
+		})
+	}()
+	// the lost subscription is replaced
+	<-bus.subscribed
+
+	publish := func(payload []byte, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Publish(ctx, messages.PaymentStatusResponseChannel, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	change := func(order uuid.UUID, status string) messages.PaymentStatusChangedMessage {
+		return messages.PaymentStatusChangedMessage{ID: uuid.NewString(), OrderID: order.String(), Status: status, UpdatedAt: "2024-01-02T15:04:05Z"}
+	}
+
+	paid := change(orderID, "paid")
+	publish(json.Marshal(paid))
+	publish(json.Marshal(change(otherOrderID, "paid")))
+	publish(json.Marshal(change(orderID, "pending")))
+	publish([]byte(`{"not":"a status change"}`), nil)
+	failed := change(orderID, "failed")
+	publish(messages.Encode(messages.TypePaymentStatusChanged, messages.PaymentStatusChangedSchemaVersion, failed, messages.WithID("m2")))
+	cloudEvent := change(orderID, "paid")
+	publish(messages.EncodeCloudEvent("/payments", messages.PaymentEventType("paid"), cloudEvent.ID,
+		messages.PaymentStatusChangedSchemaVersion, cloudEvent, messages.WithID("m3")))
+
+	for _, want := range []struct {
+		paymentID string
+		status    api.PaymentStatus
+		eventID   string
+	}{
+		{paymentID: paid.ID, status: api.PaymentStatusPaid},
+		{paymentID: failed.ID, status: api.PaymentStatusFailed, eventID: "m2"},
+		{paymentID: cloudEvent.ID, status: api.PaymentStatusPaid, eventID: "m3"},
+	} {
+		select {
+		case event := <-events:
+			if event.PaymentID.String() != want.paymentID || event.Status != want.status || event.EventID != want.eventID || event.OrderID != orderID {
+				t.Errorf("event = %+v, want payment %s %s with event ID %q", event, want.paymentID, want.status, want.eventID)
+			}
+			if event.UpdatedAt.IsZero() {
+				t.Errorf("event of payment %s has no update time", want.paymentID)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("payment %s %s was not delivered", want.paymentID, want.status)
+		}
+	}
+	select {
+	case event := <-events:
+		t.Errorf("unexpected event %+v", event)
+	default:
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("OnPaymentStatusChanged returned %v, want context.Canceled", err)
+	}
+}
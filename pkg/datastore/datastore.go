@@ -2,26 +2,29 @@ package datastore
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
 	"time"
-
-	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
-	"github.com/redis/go-redis/v9"
 )
 
-type redisStore struct {
-	Client *redis.Client
-}
+//go:generate mockgen -destination=../mocks/datastore_mocks.go -package=mocks github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore RedisStore,PaymentRepository,WorkQueue,MessageBus,PaymentCreator,Inbox
 
-//go:generate mockgen -destination=../mocks/datastore_mocks.go -package=mocks github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore RedisStore
-type RedisStore interface {
-	CloseClient() error
+// PaymentRepository stores the payment records by key
+type PaymentRepository interface {
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
 	Get(ctx context.Context, key string) (string, error)
 	Exists(ctx context.Context, key string) (bool, error)
 	Delete(ctx context.Context, key string) error
-	Publish(ctx context.Context, channel string, message interface{}) error
-	Subscribe(ctx context.Context, channel string) (<-chan *redis.Message, error)
-	SubscribeLog(ctx context.Context) (<-chan *redis.Message, error)
+	// CompareAndSwap writes value only if the record still holds old, an empty old means the record must not exist.
+	// It reports whether the write happened.
+	CompareAndSwap(ctx context.Context, key string, old string, value interface{}) (bool, error)
+	// List returns every payment record
+	List(ctx context.Context) ([]string, error)
+}
+
+// WorkQueue holds the lists the payments move through while being processed
+type WorkQueue interface {
 	LPush(ctx context.Context, key string, value interface{}) error
 	RPush(ctx context.Context, key string, value any) error
 	LRange(ctx context.Context, key string, start int64, stop int64) ([]string, error)
@@ -31,155 +34,103 @@ type RedisStore interface {
 	LIndex(ctx context.Context, key string, index int64) error
 }
 
-// NewRedisStore creates a new RedisStore instance with the given address, password, and database number.
-// It establishes a connection to the Redis server and returns the RedisStore object.
-// If the connection is successful, it logs a message indicating the successful connection.
-// If an error occurs during the connection, it returns nil and the error.
-
-func NewRedisStore(addr string, password string, db int) (RedisStore, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: password,
-		DB:       db,
-	})
-
-	ping, err := client.Ping(context.Background()).Result()
-	if err != nil {
-		return nil, err
-	}
-	if ping == "PONG" {
-		logger.Info("Connected to Redis: PONG!")
-	}
-
-	return &redisStore{Client: client}, nil
+// MessageBus publishes and receives messages on named channels
+type MessageBus interface {
+	Publish(ctx context.Context, channel string, message interface{}) error
+	Subscribe(ctx context.Context, channel string) (<-chan *Message, error)
+	SubscribeLog(ctx context.Context) (<-chan *Message, error)
 }
 
-func (s *redisStore) CloseClient() error {
-	return s.Client.Close()
+// PaymentCreator is implemented by backends that serve as repository and queue at once,
+// and can create a record, update its indexes and enqueue it in a single atomic step
+type PaymentCreator interface {
+	// CreateAndEnqueue applies op unless op.Key already exists, it reports whether the record was created.
+	// It returns ErrConflict when a key of op.Expect changed.
+	CreateAndEnqueue(ctx context.Context, op CreateOp) (bool, error)
 }
 
-// Set adds a key-value pair to the store
-func (s *redisStore) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
-	err := s.Client.Set(ctx, key, value, expiration).Err()
-	if err != nil {
-		return err
-	}
-	return nil
+// Inbox records the messages already processed so redeliveries can be skipped
+type Inbox interface {
+	// MarkProcessed records id for ttl, it reports false if id was already recorded
+	MarkProcessed(ctx context.Context, id string, ttl time.Duration) (bool, error)
+	// Forget removes id so the message can be processed again
+	Forget(ctx context.Context, id string) error
 }
 
-// Get retrieves a value from the store by its key
-func (s *redisStore) Get(ctx context.Context, key string) (string, error) {
-	value, err := s.Client.Get(ctx, key).Result()
-	if err == redis.Nil {
-		return "", nil
-	} else if err != nil {
-		return "", err
-	}
-	return value, nil
-}
+// inboxKeyPrefix keeps the inbox keys apart from the payment records
+const inboxKeyPrefix = "inbox:"
 
-// Exists checks if a key exists in the store
-func (s *redisStore) Exists(ctx context.Context, key string) (bool, error) {
-	value, err := s.Client.Exists(ctx, key).Result()
-	if err != nil {
-		return false, err
-	}
-	if value == 0 {
-		return false, nil
-	}
-	return true, nil
-}
+// ErrActiveOrderPayment is returned by repositories that allow a single active payment per order,
+// such as the PostgreSQL one, when a write would make a second payment of the order active
+var ErrActiveOrderPayment = errors.New("datastore: the order already has an active payment")
 
-// Delete removes a key-value pair from the store
-func (s *redisStore) Delete(ctx context.Context, key string) error {
-	err := s.Client.Del(ctx, key).Err()
-	if err != nil {
-		return err
-	}
-	return nil
-}
+// ErrConflict is returned by CreateAndEnqueue when a key of CreateOp.Expect no longer holds the expected value
+var ErrConflict = errors.New("datastore: record changed concurrently")
 
-// Implement your pub/sub methods here
-// Publish sends a message to a channel
-func (s *redisStore) Publish(ctx context.Context, channel string, message interface{}) error {
-	err := s.Client.Publish(ctx, channel, message).Err()
-	if err != nil {
-		return err
-	}
-	return nil
+// CreateOp describes an atomic creation: Value is stored under Key, every index key is set
+// to its value and Key is pushed to the head of Queue.
+// Every key of Expect must still hold its value, "" meaning the key must not exist, so an index
+// can be moved from the record it was read at.
+type CreateOp struct {
+	Key     string
+	Value   interface{}
+	Queue   string
+	Indexes map[string]string
+	Expect  map[string]string
 }
 
-// Subscribe subscribes to a channel and returns a channel that receives messages
-func (s *redisStore) Subscribe(ctx context.Context, channel string) (<-chan *redis.Message, error) {
-	pubsub := s.Client.Subscribe(ctx, channel)
-	_, err := pubsub.Receive(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	return pubsub.Channel(), nil
+// indexPairs returns the index keys and values of the operation in a stable order
+func (op CreateOp) indexPairs() ([]string, []string) {
+	return sortedPairs(op.Indexes)
 }
 
-// Create a Subscribe method that log all messages received from the channel
-func (s *redisStore) SubscribeLog(ctx context.Context) (<-chan *redis.Message, error) {
-	pubsub := s.Client.Subscribe(ctx, "log")
-	_, err := pubsub.Receive(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	return pubsub.Channel(), nil
+// expectPairs returns the expected keys and values of the operation in a stable order
+func (op CreateOp) expectPairs() ([]string, []string) {
+	return sortedPairs(op.Expect)
 }
 
-// Create a LPUSH method that will add a message to a list
-func (s *redisStore) LPush(ctx context.Context, key string, value interface{}) error {
-	err := s.Client.LPush(ctx, key, value).Err()
-	if err != nil {
-		return err
+func sortedPairs(m map[string]string) ([]string, []string) {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
 	}
-	return nil
-}
+	sort.Strings(keys)
 
-// RPush appends items into the end of a list defined by its key
-func (s *redisStore) RPush(ctx context.Context, key string, value any) error {
-	_, err := s.Client.RPush(ctx, key, value).Result()
-	return err
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i] = m[key]
+	}
+	return keys, values
 }
 
-// LRange to retrieve every value stored in a list defined by its key
-func (s *redisStore) LRange(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
-	return s.Client.LRange(ctx, key, start, stop).Result()
+// RedisStore is a backend that serves as repository, queue and message bus at once
+type RedisStore interface {
+	PaymentRepository
+	WorkQueue
+	MessageBus
+	PaymentCreator
+	Inbox
+	CloseClient() error
 }
 
-// Create a BRPOP/BLPOP method that will remove and return the first element of a list
-func (s *redisStore) BRPop(ctx context.Context, key string) (string, error) {
-	value, err := s.Client.BRPop(ctx, 0, key).Result()
-	if err != nil {
-		return "", err
-	}
-	return value[1], nil
+// Message is a message received from a MessageBus channel
+type Message struct {
+	Channel string
+	Payload string
 }
 
-// Create a BLMOVE method that will move an element from a list to another list atomically
-func (s *redisStore) BLMOVE(ctx context.Context, source string, destination string) (string, error) {
-	value, err := s.Client.BLMove(ctx, source, destination, "RIGHT", "LEFT", 1*time.Hour).Result()
-	if err != nil {
-		return "", err
-	}
-	return value, nil
+func (m *Message) String() string {
+	return fmt.Sprintf("Message<%s: %s>", m.Channel, m.Payload)
 }
 
-// Create a LREM method that will remove the first count occurrences of elements equal to value from the list stored at key
-func (s *redisStore) LREM(ctx context.Context, key string, count int64, value interface{}) error {
-	err := s.Client.LRem(ctx, key, count, value).Err()
-	if err != nil {
-		return err
+// valueString converts the values accepted by Set into the text kept by the stores
+func valueString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
 	}
-	return nil
-}
-
-
-func (s *redisStore) LIndex(ctx context.Context, key string, index int64) error {
-	_, err := s.Client.LIndex(ctx, key, index).Result()
-	return err
 }
@@ -0,0 +1,430 @@
+package datastore
+
+import (
+	"context"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+	"github.com/redis/go-redis/v9"
+)
+
+// subscriberBuffer is the number of messages kept for a slow subscriber before new ones are dropped
+const subscriberBuffer = 100
+
+// blockingTimeout mirrors the timeout used by the Redis BLMOVE
+const blockingTimeout = 1 * time.Hour
+
+type memoryEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+type memorySubscriber struct {
+	channel string
+	ch      chan *Message
+}
+
+type memoryStore struct {
+	mu          sync.Mutex
+	kv          map[string]memoryEntry
+	lists       map[string][]string
+	subscribers map[*memorySubscriber]struct{}
+	// pushed is closed and replaced every time a list grows, waking the blocking pops
+	pushed chan struct{}
+	closed bool
+}
+
+// NewMemoryStore creates a RedisStore that keeps everything in the process memory.
+// It behaves like Redis for the operations used by the service, including the blocking
+// list moves and pub/sub, so the service can run as a single binary and in unit tests.
+// Data is lost when the process exits.
+func NewMemoryStore() RedisStore {
+	return &memoryStore{
+		kv:          make(map[string]memoryEntry),
+		lists:       make(map[string][]string),
+		subscribers: make(map[*memorySubscriber]struct{}),
+		pushed:      make(chan struct{}),
+	}
+}
+
+// CloseClient closes every subscription
+func (s *memoryStore) CloseClient() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	for sub := range s.subscribers {
+		close(sub.ch)
+		delete(s.subscribers, sub)
+	}
+	// wake the blocking pops so they return
+	s.notifyPush()
+	return nil
+}
+
+// Set adds a key-value pair to the store
+func (s *memoryStore) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := memoryEntry{value: valueString(value)}
+	if expiration > 0 {
+		entry.expiresAt = time.Now().Add(expiration)
+	}
+	s.kv[key] = entry
+	return nil
+}
+
+// Get retrieves a value from the store by its key
+func (s *memoryStore) Get(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.lookup(key)
+	if !ok {
+		return "", nil
+	}
+	return entry.value, nil
+}
+
+// Exists checks if a key exists in the store
+func (s *memoryStore) Exists(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.lookup(key)
+	return ok, nil
+}
+
+// Delete removes a key-value pair from the store
+func (s *memoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.kv, key)
+	return nil
+}
+
+// CompareAndSwap replaces the value only if it still holds old, keeping its expiration
+func (s *memoryStore) CompareAndSwap(ctx context.Context, key string, old string, value interface{}) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, _ := s.lookup(key)
+	if entry.value != old {
+		return false, nil
+	}
+	entry.value = valueString(value)
+	s.kv[key] = entry
+	return true, nil
+}
+
+// CreateAndEnqueue creates the record, its indexes and enqueues it while holding the lock
+func (s *memoryStore) CreateAndEnqueue(ctx context.Context, op CreateOp) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.lookup(op.Key); ok {
+		return false, nil
+	}
+	for key, expected := range op.Expect {
+		current := ""
+		if entry, ok := s.lookup(key); ok {
+			current = entry.value
+		}
+		if current != expected {
+			return false, ErrConflict
+		}
+	}
+	s.kv[op.Key] = memoryEntry{value: valueString(op.Value)}
+	for key, value := range op.Indexes {
+		s.kv[key] = memoryEntry{value: value}
+	}
+	s.lists[op.Queue] = append([]string{op.Key}, s.lists[op.Queue]...)
+	s.notifyPush()
+	return true, nil
+}
+
+// List returns the values of every payment key
+func (s *memoryStore) List(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.kv))
+	for key := range s.kv {
+		if ok, _ := path.Match(paymentKeyPattern, key); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if entry, ok := s.lookup(key); ok {
+			values = append(values, entry.value)
+		}
+	}
+	return values, nil
+}
+
+// MarkProcessed records the message id, reporting false if it was already recorded
+func (s *memoryStore) MarkProcessed(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := inboxKeyPrefix + id
+	if _, ok := s.lookup(key); ok {
+		return false, nil
+	}
+	entry := memoryEntry{value: time.Now().UTC().Format(time.RFC3339)}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	s.kv[key] = entry
+	return true, nil
+}
+
+// Forget removes the message id from the inbox
+func (s *memoryStore) Forget(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.kv, inboxKeyPrefix+id)
+	return nil
+}
+
+// lookup returns a live entry, dropping it if it expired. The caller must hold the lock.
+func (s *memoryStore) lookup(key string) (memoryEntry, bool) {
+	entry, ok := s.kv[key]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if entry.expired(time.Now()) {
+		delete(s.kv, key)
+		return memoryEntry{}, false
+	}
+	return entry, true
+}
+
+// Publish sends a message to every subscriber of the channel, dropping it for subscribers that are too slow
+func (s *memoryStore) Publish(ctx context.Context, channel string, message interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payload := valueString(message)
+	for sub := range s.subscribers {
+		if sub.channel != channel {
+			continue
+		}
+		select {
+		case sub.ch <- &Message{Channel: channel, Payload: payload}:
+		default:
+			logger.Error("memory store: dropping message for slow subscriber of", channel)
+		}
+	}
+	return nil
+}
+
+// Subscribe subscribes to a channel and returns a channel that receives messages
+// The subscription is closed when ctx is done.
+func (s *memoryStore) Subscribe(ctx context.Context, channel string) (<-chan *Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, redis.ErrClosed
+	}
+
+	sub := &memorySubscriber{channel: channel, ch: make(chan *Message, subscriberBuffer)}
+	s.subscribers[sub] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subscribers[sub]; ok {
+			delete(s.subscribers, sub)
+			close(sub.ch)
+		}
+	}()
+
+	return sub.ch, nil
+}
+
+// SubscribeLog subscribes to the log channel
+func (s *memoryStore) SubscribeLog(ctx context.Context) (<-chan *Message, error) {
+	return s.Subscribe(ctx, "log")
+}
+
+// LPush adds a value to the head of a list
+func (s *memoryStore) LPush(ctx context.Context, key string, value interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lists[key] = append([]string{valueString(value)}, s.lists[key]...)
+	s.notifyPush()
+	return nil
+}
+
+// RPush appends items into the end of a list defined by its key
+func (s *memoryStore) RPush(ctx context.Context, key string, value any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lists[key] = append(s.lists[key], valueString(value))
+	s.notifyPush()
+	return nil
+}
+
+// LRange to retrieve the values stored in a list between start and stop, negative indexes count from the tail
+func (s *memoryStore) LRange(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := s.lists[key]
+	n := int64(len(list))
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if stop < 0 {
+		stop = n + stop
+	}
+	stop = min(stop, n-1)
+	if start > stop || start >= n {
+		return []string{}, nil
+	}
+	return append([]string(nil), list[start:stop+1]...), nil
+}
+
+// BRPop removes and returns the last element of a list, blocking until there is one
+func (s *memoryStore) BRPop(ctx context.Context, key string) (string, error) {
+	return s.blockingPop(ctx, 0, func() (string, bool) {
+		list := s.lists[key]
+		if len(list) == 0 {
+			return "", false
+		}
+		value := list[len(list)-1]
+		s.setList(key, list[:len(list)-1])
+		return value, true
+	})
+}
+
+// BLMOVE moves the last element of source to the head of destination atomically, blocking until there is one
+func (s *memoryStore) BLMOVE(ctx context.Context, source string, destination string) (string, error) {
+	return s.blockingPop(ctx, blockingTimeout, func() (string, bool) {
+		list := s.lists[source]
+		if len(list) == 0 {
+			return "", false
+		}
+		value := list[len(list)-1]
+		s.setList(source, list[:len(list)-1])
+		s.lists[destination] = append([]string{value}, s.lists[destination]...)
+		return value, true
+	})
+}
+
+// LREM removes count occurrences of value from the head, from the tail when count is negative, or all of them when it is zero
+func (s *memoryStore) LREM(ctx context.Context, key string, count int64, value interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target := valueString(value)
+	list := s.lists[key]
+	remove := make(map[int]bool)
+
+	if count >= 0 {
+		for i := 0; i < len(list) && (count == 0 || int64(len(remove)) < count); i++ {
+			if list[i] == target {
+				remove[i] = true
+			}
+		}
+	} else {
+		for i := len(list) - 1; i >= 0 && int64(len(remove)) < -count; i-- {
+			if list[i] == target {
+				remove[i] = true
+			}
+		}
+	}
+
+	kept := make([]string, 0, len(list)-len(remove))
+	for i, item := range list {
+		if !remove[i] {
+			kept = append(kept, item)
+		}
+	}
+	s.setList(key, kept)
+	return nil
+}
+
+// LIndex checks that the list has an element at index, negative indexes count from the tail
+func (s *memoryStore) LIndex(ctx context.Context, key string, index int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := int64(len(s.lists[key]))
+	if index < 0 {
+		index = n + index
+	}
+	if index < 0 || index >= n {
+		return redis.Nil
+	}
+	return nil
+}
+
+// blockingPop runs pop until it succeeds, waiting for pushes in between.
+// It gives up with redis.Nil after timeout (zero waits forever) and with the context error when ctx is done.
+func (s *memoryStore) blockingPop(ctx context.Context, timeout time.Duration, pop func() (string, bool)) (string, error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return "", redis.ErrClosed
+		}
+		value, ok := pop()
+		pushed := s.pushed
+		s.mu.Unlock()
+		if ok {
+			return value, nil
+		}
+
+		select {
+		case <-pushed:
+		case <-expired:
+			return "", redis.Nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// setList stores a list, removing it when empty as Redis does. The caller must hold the lock.
+func (s *memoryStore) setList(key string, list []string) {
+	if len(list) == 0 {
+		delete(s.lists, key)
+		return
+	}
+	s.lists[key] = list
+}
+
+// notifyPush wakes every blocking pop. The caller must hold the lock.
+func (s *memoryStore) notifyPush() {
+	close(s.pushed)
+	s.pushed = make(chan struct{})
+}
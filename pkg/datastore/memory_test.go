@@ -0,0 +1,220 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreCreateAndEnqueue(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		seed        map[string]string
+		op          CreateOp
+		wantCreated bool
+		wantErr     error
+		wantKV      map[string]string
+		wantQueue   []string
+	}{
+		{
+			name:        "creates the record, its indexes and enqueues it",
+			op:          CreateOp{Key: "p1", Value: "v1", Queue: "q", Indexes: map[string]string{"order:o1": "p1"}},
+			wantCreated: true,
+			wantKV:      map[string]string{"p1": "v1", "order:o1": "p1"},
+			wantQueue:   []string{"p1"},
+		},
+		{
+			name:      "leaves an existing record alone",
+			seed:      map[string]string{"p1": "old"},
+			op:        CreateOp{Key: "p1", Value: "v1", Queue: "q", Indexes: map[string]string{"order:o1": "p1"}},
+			wantKV:    map[string]string{"p1": "old", "order:o1": ""},
+			wantQueue: nil,
+		},
+		{
+			name: "moves an index from the expected record",
+			seed: map[string]string{"order:o1": "p0"},
+			op: CreateOp{Key: "p1", Value: "v1", Queue: "q",
+				Indexes: map[string]string{"order:o1": "p1"}, Expect: map[string]string{"order:o1": "p0"}},
+			wantCreated: true,
+			wantKV:      map[string]string{"p1": "v1", "order:o1": "p1"},
+			wantQueue:   []string{"p1"},
+		},
+		{
+			name: "expects a missing key with an empty value",
+			op: CreateOp{Key: "p1", Value: "v1", Queue: "q",
+				Indexes: map[string]string{"order:o1": "p1"}, Expect: map[string]string{"order:o1": ""}},
+			wantCreated: true,
+			wantKV:      map[string]string{"p1": "v1", "order:o1": "p1"},
+			wantQueue:   []string{"p1"},
+		},
+		{
+			name: "writes nothing when an expected key changed",
+			seed: map[string]string{"order:o1": "p2"},
+			op: CreateOp{Key: "p1", Value: "v1", Queue: "q",
+				Indexes: map[string]string{"order:o1": "p1"}, Expect: map[string]string{"order:o1": "p0"}},
+			wantErr:   ErrConflict,
+			wantKV:    map[string]string{"p1": "", "order:o1": "p2"},
+			wantQueue: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStore()
+			for key, value := range tt.seed {
+				if err := s.Set(ctx, key, value, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			created, err := s.CreateAndEnqueue(ctx, tt.op)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if created != tt.wantCreated {
+				t.Errorf("created = %v, want %v", created, tt.wantCreated)
+			}
+			for key, want := range tt.wantKV {
+				if got, _ := s.Get(ctx, key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+			queue, _ := s.LRange(ctx, "q", 0, -1)
+			if len(queue) != len(tt.wantQueue) || (len(queue) > 0 && queue[0] != tt.wantQueue[0]) {
+				t.Errorf("queue = %v, want %v", queue, tt.wantQueue)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreKeys(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	defer s.CloseClient()
+
+	if err := s.Set(ctx, "short", "lived", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, "p1", []byte("v1"), 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if value, _ := s.Get(ctx, "short"); value != "" {
+		t.Errorf("expired key holds %q", value)
+	}
+	if exists, _ := s.Exists(ctx, "p1"); !exists {
+		t.Error("p1 does not exist")
+	}
+
+	if swapped, _ := s.CompareAndSwap(ctx, "p1", "old", "v2"); swapped {
+		t.Error("swapped a key that does not hold old")
+	}
+	if swapped, _ := s.CompareAndSwap(ctx, "p1", "v1", "v2"); !swapped {
+		t.Error("did not swap a key holding old")
+	}
+	if value, _ := s.Get(ctx, "p1"); value != "v2" {
+		t.Errorf("p1 = %q, want v2", value)
+	}
+
+	if err := s.Delete(ctx, "p1"); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := s.Exists(ctx, "p1"); exists {
+		t.Error("p1 exists after its deletion")
+	}
+}
+
+func TestMemoryStoreLists(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	defer s.CloseClient()
+
+	for _, value := range []string{"a", "b", "a", "c"} {
+		if err := s.LPush(ctx, "pending", value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertList := func(key string, want ...string) {
+		t.Helper()
+		got, err := s.LRange(ctx, key, 0, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, append([]string{}, want...)) {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+	assertList("pending", "c", "a", "b", "a")
+	if got, _ := s.LRange(ctx, "pending", -2, -1); !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Errorf("tail = %v, want [b a]", got)
+	}
+
+	// the oldest element moves first, as with BLMOVE RIGHT LEFT
+	if value, err := s.BLMOVE(ctx, "pending", "processing"); err != nil || value != "a" {
+		t.Fatalf("BLMOVE = %q, %v, want a", value, err)
+	}
+	assertList("processing", "a")
+	if err := s.LREM(ctx, "pending", 0, "a"); err != nil {
+		t.Fatal(err)
+	}
+	assertList("pending", "c", "b")
+
+	// a blocking pop waits for the next push
+	popped := make(chan string)
+	go func() {
+		value, _ := s.BRPop(ctx, "paid")
+		popped <- value
+	}()
+	if err := s.RPush(ctx, "paid", "d"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case value := <-popped:
+		if value != "d" {
+			t.Errorf("BRPop = %q, want d", value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("BRPop was not woken by the push")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := s.BRPop(cancelled, "empty"); !errors.Is(err, context.Canceled) {
+		t.Errorf("BRPop on a cancelled context: err = %v", err)
+	}
+}
+
+func TestMemoryStorePubSub(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := NewMemoryStore()
+	defer s.CloseClient()
+
+	messages, err := s.Subscribe(ctx, "statuses")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Publish(ctx, "other", "ignored"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Publish(ctx, "statuses", "paid"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-messages; msg.Channel != "statuses" || msg.Payload != "paid" {
+		t.Errorf("received %s, want paid on statuses", msg)
+	}
+
+	// the subscription ends with its context
+	cancel()
+	select {
+	case _, ok := <-messages:
+		if ok {
+			t.Error("received a message after the subscription ended")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription still open after its context was cancelled")
+	}
+}
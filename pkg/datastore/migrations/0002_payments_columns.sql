@@ -0,0 +1,29 @@
+-- Relational columns of the payment document so finance can query without parsing JSON, written by
+-- the repository along with the document. The version column takes the version of the document,
+-- which compare-and-swap writes check.
+ALTER TABLE payments
+    ADD COLUMN IF NOT EXISTS order_id UUID    NULL,
+    ADD COLUMN IF NOT EXISTS status   TEXT    NULL,
+    ADD COLUMN IF NOT EXISTS amount   NUMERIC NULL,
+    ADD COLUMN IF NOT EXISTS currency TEXT    NULL;
+
+-- Prices written before they had a currency are bare decimals in BRL
+UPDATE payments SET
+    order_id = (data ->> 'OrderID')::uuid,
+    status   = data ->> 'Status',
+    amount   = CASE jsonb_typeof(data -> 'Price')
+                   WHEN 'object' THEN (data -> 'Price' ->> 'amount')::numeric
+                   ELSE (data ->> 'Price')::numeric
+               END,
+    currency = CASE jsonb_typeof(data -> 'Price')
+                   WHEN 'object' THEN upper(data -> 'Price' ->> 'currency')
+                   ELSE 'BRL'
+               END,
+    version  = COALESCE((data ->> 'Version')::bigint, 0);
+
+CREATE INDEX IF NOT EXISTS payments_order_id_idx ON payments (order_id);
+CREATE INDEX IF NOT EXISTS payments_status_idx ON payments (status);
+
+-- A single active payment per order, failed, expired, voided and closed payments don't count
+CREATE UNIQUE INDEX IF NOT EXISTS payments_active_order_idx ON payments (order_id)
+    WHERE status NOT IN ('failed', 'expired', 'voided', 'closed');
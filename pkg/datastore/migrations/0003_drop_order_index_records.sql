@@ -0,0 +1,3 @@
+-- The order index records the service kept next to the payments are no longer written here,
+-- payments_active_order_idx enforces a single active payment per order instead.
+DELETE FROM payments WHERE id LIKE 'order:%';
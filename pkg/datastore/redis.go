@@ -0,0 +1,438 @@
+package datastore
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/SOAT1StackGoLang/msvc-payments/pkg/middleware"
+	"github.com/redis/go-redis/v9"
+)
+
+type redisStore struct {
+	Client redis.UniversalClient
+	// keyTag prefixes the record keys with a hash tag on Redis Cluster
+	keyTag string
+}
+
+// RedisOptions holds the settings used to connect to Redis.
+// A single address connects to a standalone node, MasterName switches to Sentinel
+// (Addrs are then the sentinels) and ClusterMode connects to a Redis Cluster seeded by Addrs.
+type RedisOptions struct {
+	Addrs    []string
+	Username string
+	Password string
+	DB       int
+	PoolSize int
+	TLS      TLSOptions
+
+	// Sentinel settings
+	MasterName       string
+	SentinelUsername string
+	SentinelPassword string
+
+	// ClusterMode forces a cluster client even when a single seed address is given
+	ClusterMode bool
+	// KeyTag is the hash tag of the queue payments are created in, "payments" for "{payments}:pending".
+	// On Redis Cluster the record keys are stored under it, so a creation touches a single slot.
+	KeyTag string
+}
+
+// TLSOptions holds the TLS settings used to connect to Redis.
+// CAFile is optional and defaults to the system pool, CertFile and KeyFile enable client certificates.
+type TLSOptions struct {
+	Enabled            bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// NewRedisStore creates a new RedisStore instance with the given options.
+// It establishes a connection to the Redis server and returns the RedisStore object.
+// If the connection is successful, it logs a message indicating the successful connection.
+// If an error occurs during the connection, it returns nil and the error.
+func NewRedisStore(opts RedisOptions) (RedisStore, error) {
+	tlsConfig, err := opts.TLS.config()
+	if err != nil {
+		return nil, err
+	}
+
+	universal := &redis.UniversalOptions{
+		Addrs:            opts.Addrs,
+		Username:         opts.Username,
+		Password:         opts.Password,
+		DB:               opts.DB,
+		PoolSize:         opts.PoolSize,
+		TLSConfig:        tlsConfig,
+		MasterName:       opts.MasterName,
+		SentinelUsername: opts.SentinelUsername,
+		SentinelPassword: opts.SentinelPassword,
+	}
+
+	var client redis.UniversalClient
+	switch {
+	case opts.ClusterMode && opts.MasterName != "":
+		return nil, fmt.Errorf("redis cluster mode and sentinel master name are mutually exclusive")
+	case opts.ClusterMode:
+		client = redis.NewClusterClient(universal.Cluster())
+	default:
+		// NewUniversalClient picks sentinel when MasterName is set,
+		// cluster when there are several addresses and a single node otherwise
+		client = redis.NewUniversalClient(universal)
+	}
+
+	ping, err := client.Ping(context.Background()).Result()
+	if err != nil {
+		return nil, err
+	}
+	if ping == "PONG" {
+		logger.Info("Connected to Redis: PONG!")
+	}
+
+	store := &redisStore{Client: client}
+	if _, ok := client.(*redis.ClusterClient); ok {
+		store.keyTag = opts.KeyTag
+	}
+	return store, nil
+}
+
+// key returns the key a record is stored under, prefixed with the hash tag on Redis Cluster
+func (s *redisStore) key(key string) string {
+	if s.keyTag == "" {
+		return key
+	}
+	return "{" + s.keyTag + "}:" + key
+}
+
+// config builds the *tls.Config described by the options, it returns nil when TLS is disabled
+func (o TLSOptions) config() (*tls.Config, error) {
+	if !o.Enabled {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify, //nolint:gosec // opt-in for self-signed development setups
+	}
+
+	if o.CAFile != "" {
+		ca, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading redis CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in redis CA file %s", o.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading redis client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+func (s *redisStore) CloseClient() error {
+	return s.Client.Close()
+}
+
+// Set adds a key-value pair to the store
+func (s *redisStore) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	err := s.Client.Set(ctx, s.key(key), value, expiration).Err()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Get retrieves a value from the store by its key
+func (s *redisStore) Get(ctx context.Context, key string) (string, error) {
+	value, err := s.Client.Get(ctx, s.key(key)).Result()
+	if err == redis.Nil {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// Exists checks if a key exists in the store
+func (s *redisStore) Exists(ctx context.Context, key string) (bool, error) {
+	value, err := s.Client.Exists(ctx, s.key(key)).Result()
+	if err != nil {
+		return false, err
+	}
+	if value == 0 {
+		return false, nil
+	}
+	return true, nil
+}
+
+// Delete removes a key-value pair from the store
+func (s *redisStore) Delete(ctx context.Context, key string) error {
+	err := s.Client.Del(ctx, s.key(key)).Err()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// compareAndSwapScript sets KEYS[1] to ARGV[2] only if it currently holds ARGV[1], a missing key holds ""
+var compareAndSwapScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current == false then
+	current = ''
+end
+if current ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'KEEPTTL')
+return 1
+`)
+
+// CompareAndSwap replaces the value only if it still holds old, atomically
+func (s *redisStore) CompareAndSwap(ctx context.Context, key string, old string, value interface{}) (bool, error) {
+	swapped, err := compareAndSwapScript.Run(ctx, s.Client, []string{s.key(key)}, old, value).Int()
+	if err != nil {
+		return false, err
+	}
+	return swapped == 1, nil
+}
+
+// createAndEnqueueScript creates KEYS[1] with ARGV[1] unless it exists, or returns -1 unless every
+// expected key KEYS[3+ARGV[2]..] holds the matching ARGV value, a missing key holds "".
+// It then sets the ARGV[2] index keys KEYS[3..] to the matching ARGV values and pushes the last ARGV,
+// the unprefixed record key, to the queue KEYS[2].
+var createAndEnqueueScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+local indexes = tonumber(ARGV[2])
+for i = 3 + indexes, #KEYS do
+	local current = redis.call('GET', KEYS[i])
+	if current == false then
+		current = ''
+	end
+	if current ~= ARGV[i] then
+		return -1
+	end
+end
+redis.call('SET', KEYS[1], ARGV[1])
+for i = 3, 2 + indexes do
+	redis.call('SET', KEYS[i], ARGV[i])
+end
+redis.call('LPUSH', KEYS[2], ARGV[#KEYS + 1])
+return 1
+`)
+
+// ErrCrossSlot is returned by CreateAndEnqueue on Redis Cluster when the record keys and the queue
+// don't share a hash tag, as the creation could not be applied atomically
+var ErrCrossSlot = errors.New("datastore: atomic creation on redis cluster requires the queue and the records to share a hash tag")
+
+// CreateAndEnqueue creates the record, its indexes and enqueues it in one round trip.
+// Redis Cluster runs the script only when every key is in the same slot: the record keys are stored
+// under KeyTag there, and the creation is refused unless the queue has the same hash tag.
+func (s *redisStore) CreateAndEnqueue(ctx context.Context, op CreateOp) (bool, error) {
+	indexKeys, indexValues := op.indexPairs()
+	expectKeys, expectValues := op.expectPairs()
+
+	if _, ok := s.Client.(*redis.ClusterClient); ok {
+		if s.keyTag == "" || !strings.HasPrefix(op.Queue, "{"+s.keyTag+"}") {
+			return false, ErrCrossSlot
+		}
+	}
+
+	keys := []string{s.key(op.Key), op.Queue}
+	for _, key := range append(indexKeys, expectKeys...) {
+		keys = append(keys, s.key(key))
+	}
+	args := make([]interface{}, 0, len(keys)+1)
+	args = append(args, op.Value, len(indexKeys))
+	for _, value := range append(indexValues, expectValues...) {
+		args = append(args, value)
+	}
+	args = append(args, op.Key)
+
+	created, err := createAndEnqueueScript.Run(ctx, s.Client, keys, args...).Int()
+	if err != nil {
+		return false, err
+	}
+	if created == -1 {
+		return false, ErrConflict
+	}
+	return created == 1, nil
+}
+
+// paymentKeyPattern matches the UUID keys payments are stored under
+const paymentKeyPattern = "????????-????-????-????-????????????"
+
+// List scans the keyspace for payment keys and returns their values
+func (s *redisStore) List(ctx context.Context) ([]string, error) {
+	var keys []string
+	scan := func(ctx context.Context, client redis.UniversalClient) error {
+		iter := client.Scan(ctx, 0, s.key(paymentKeyPattern), 100).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		return iter.Err()
+	}
+
+	var err error
+	if cluster, ok := s.Client.(*redis.ClusterClient); ok {
+		var mu sync.Mutex
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return scan(ctx, node)
+		})
+	} else {
+		err = scan(ctx, s.Client)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value, err := s.Client.Get(ctx, key).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		// the key may have been deleted after the scan
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// MarkProcessed records the message id with SET NX, reporting false if it was already recorded
+func (s *redisStore) MarkProcessed(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	return s.Client.SetNX(ctx, inboxKeyPrefix+id, time.Now().UTC().Format(time.RFC3339), ttl).Result()
+}
+
+// Forget removes the message id from the inbox
+func (s *redisStore) Forget(ctx context.Context, id string) error {
+	return s.Client.Del(ctx, inboxKeyPrefix+id).Err()
+}
+
+// Implement your pub/sub methods here
+// Publish sends a message to a channel
+func (s *redisStore) Publish(ctx context.Context, channel string, message interface{}) error {
+	err := s.Client.Publish(ctx, channel, message).Err()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Subscribe subscribes to a channel and returns a channel that receives messages
+// The subscription is closed when ctx is done.
+func (s *redisStore) Subscribe(ctx context.Context, channel string) (<-chan *Message, error) {
+	pubsub := s.Client.Subscribe(ctx, channel)
+	_, err := pubsub.Receive(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return forwardMessages(ctx, pubsub), nil
+}
+
+// Create a Subscribe method that log all messages received from the channel
+func (s *redisStore) SubscribeLog(ctx context.Context) (<-chan *Message, error) {
+	return s.Subscribe(ctx, "log")
+}
+
+// forwardMessages converts the messages of a redis subscription into datastore messages
+func forwardMessages(ctx context.Context, pubsub *redis.PubSub) <-chan *Message {
+	out := make(chan *Message)
+	go func() {
+		defer close(out)
+		defer pubsub.Close()
+
+		in := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-in:
+				if !ok {
+					return
+				}
+				select {
+				case out <- &Message{Channel: msg.Channel, Payload: msg.Payload}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Create a LPUSH method that will add a message to a list
+func (s *redisStore) LPush(ctx context.Context, key string, value interface{}) error {
+	err := s.Client.LPush(ctx, key, value).Err()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RPush appends items into the end of a list defined by its key
+func (s *redisStore) RPush(ctx context.Context, key string, value any) error {
+	_, err := s.Client.RPush(ctx, key, value).Result()
+	return err
+}
+
+// LRange to retrieve every value stored in a list defined by its key
+func (s *redisStore) LRange(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	return s.Client.LRange(ctx, key, start, stop).Result()
+}
+
+// Create a BRPOP/BLPOP method that will remove and return the first element of a list
+func (s *redisStore) BRPop(ctx context.Context, key string) (string, error) {
+	value, err := s.Client.BRPop(ctx, 0, key).Result()
+	if err != nil {
+		return "", err
+	}
+	return value[1], nil
+}
+
+// Create a BLMOVE method that will move an element from a list to another list atomically
+func (s *redisStore) BLMOVE(ctx context.Context, source string, destination string) (string, error) {
+	value, err := s.Client.BLMove(ctx, source, destination, "RIGHT", "LEFT", 1*time.Hour).Result()
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// Create a LREM method that will remove the first count occurrences of elements equal to value from the list stored at key
+func (s *redisStore) LREM(ctx context.Context, key string, count int64, value interface{}) error {
+	err := s.Client.LRem(ctx, key, count, value).Err()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *redisStore) LIndex(ctx context.Context, key string, index int64) error {
+	_, err := s.Client.LIndex(ctx, key, index).Result()
+	return err
+}
@@ -0,0 +1,86 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestClusterCreateAndEnqueueRequiresSharedHashTag(t *testing.T) {
+	tests := []struct {
+		name   string
+		keyTag string
+		queue  string
+	}{
+		{name: "records without a hash tag", queue: "{payments}:pending"},
+		{name: "queue without a hash tag", keyTag: "payments", queue: "payments_pending"},
+		{name: "queue with another hash tag", keyTag: "payments", queue: "{orders}:pending"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the creation is refused before reaching the cluster
+			client := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{"127.0.0.1:0"}})
+			t.Cleanup(func() { _ = client.Close() })
+			s := &redisStore{Client: client, keyTag: tt.keyTag}
+
+			_, err := s.CreateAndEnqueue(context.Background(), CreateOp{Key: "payment", Value: "{}", Queue: tt.queue})
+			if !errors.Is(err, ErrCrossSlot) {
+				t.Errorf("err = %v, want ErrCrossSlot", err)
+			}
+		})
+	}
+
+	s := &redisStore{keyTag: "payments"}
+	if key := s.key("order:1"); key != "{payments}:order:1" {
+		t.Errorf("key = %q, want it under the hash tag", key)
+	}
+}
+
+func TestTLSOptionsConfig(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    TLSOptions
+		wantErr string
+	}{
+		{name: "missing CA file", opts: TLSOptions{Enabled: true, CAFile: filepath.Join(dir, "missing.pem")}, wantErr: "reading redis CA file"},
+		{name: "CA file without certificates", opts: TLSOptions{Enabled: true, CAFile: notPEM}, wantErr: "no certificates found"},
+		{name: "key without its certificate", opts: TLSOptions{Enabled: true, KeyFile: notPEM}, wantErr: "loading redis client certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.opts.config()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if cfg, err := (TLSOptions{}).config(); cfg != nil || err != nil {
+		t.Errorf("disabled TLS = %v, %v, want no config", cfg, err)
+	}
+	cfg, err := TLSOptions{Enabled: true, ServerName: "redis.internal"}.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ServerName != "redis.internal" || cfg.RootCAs != nil || cfg.MinVersion == 0 {
+		t.Errorf("config = %+v, want the server name on the system pool", cfg)
+	}
+}
+
+func TestNewRedisStoreRejectsClusterWithSentinel(t *testing.T) {
+	_, err := NewRedisStore(RedisOptions{Addrs: []string{"127.0.0.1:0"}, ClusterMode: true, MasterName: "payments"})
+	if err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("err = %v, want cluster and sentinel to be mutually exclusive", err)
+	}
+}
@@ -0,0 +1,87 @@
+package messages
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CloudEventsSpecVersion is the CloudEvents version written by EncodeCloudEvent
+const CloudEventsSpecVersion = "1.0"
+
+// PaymentEventTypePrefix prefixes the CloudEvents type of payment events, com.soat.payment.paid
+const PaymentEventTypePrefix = "com.soat.payment."
+
+// PaymentEventType returns the CloudEvents type of a payment reaching status
+func PaymentEventType(status string) string {
+	return PaymentEventTypePrefix + status
+}
+
+// CloudEvent is a CloudEvents 1.0 event in the structured JSON format
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data"`
+
+	// extension attributes carrying the envelope metadata
+	SchemaVersion int    `json:"schemaversion,omitempty"`
+	CorrelationID string `json:"correlationid,omitempty"`
+	CausationID   string `json:"causationid,omitempty"`
+}
+
+// NewCloudEvent builds a CloudEvent from an envelope, subject is usually the payment ID
+func NewCloudEvent(source, ceType, subject string, e Envelope) CloudEvent {
+	return CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              e.ID,
+		Source:          source,
+		Type:            ceType,
+		Subject:         subject,
+		Time:            e.OccurredAt,
+		DataContentType: "application/json",
+		Data:            e.Payload,
+		SchemaVersion:   e.Version,
+		CorrelationID:   e.CorrelationID,
+		CausationID:     e.CausationID,
+	}
+}
+
+// EncodeCloudEvent wraps payload in a CloudEvent of ceType and encodes it as structured JSON
+func EncodeCloudEvent(source, ceType, subject string, version int, payload any, opts ...EnvelopeOption) ([]byte, error) {
+	e, err := NewEnvelope(ceType, version, payload, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(NewCloudEvent(source, ceType, subject, e))
+}
+
+// Envelope converts the event into an envelope, keeping its CloudEvents type
+func (ce CloudEvent) Envelope() Envelope {
+	return Envelope{
+		ID:            ce.ID,
+		Type:          ce.Type,
+		Version:       ce.SchemaVersion,
+		OccurredAt:    ce.Time,
+		CorrelationID: ce.CorrelationID,
+		CausationID:   ce.CausationID,
+		Payload:       ce.Data,
+	}
+}
+
+// DecodeCloudEvent reads a CloudEvent in the structured JSON format
+func DecodeCloudEvent(data []byte) (CloudEvent, error) {
+	var ce CloudEvent
+	if err := json.Unmarshal(data, &ce); err != nil {
+		return CloudEvent{}, fmt.Errorf("decoding cloudevent: %w", err)
+	}
+	if ce.SpecVersion == "" || ce.ID == "" || ce.Type == "" || ce.Source == "" {
+		return CloudEvent{}, errors.New("decoding cloudevent: missing specversion, id, type or source")
+	}
+	return ce, nil
+}
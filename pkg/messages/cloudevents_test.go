@@ -0,0 +1,62 @@
+package messages
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEncodeCloudEvent(t *testing.T) {
+	payload := PaymentStatusChangedMessage{ID: "p1", OrderID: "o1", Status: "paid"}
+	data, err := EncodeCloudEvent("/payments", PaymentEventType("paid"), "p1", PaymentStatusChangedSchemaVersion, payload,
+		WithID("m1"), WithCorrelationID("c1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the attributes are the lower case CloudEvents ones
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"specversion", "id", "source", "type", "subject", "time", "datacontenttype", "data", "schemaversion", "correlationid"} {
+		if _, ok := attributes[name]; !ok {
+			t.Errorf("attribute %s is missing from %s", name, data)
+		}
+	}
+
+	ce, err := DecodeCloudEvent(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ce.SpecVersion != CloudEventsSpecVersion || ce.Type != "com.soat.payment.paid" || ce.Source != "/payments" || ce.Subject != "p1" {
+		t.Errorf("cloudevent = %+v", ce)
+	}
+
+	// Decode reads the event as an envelope keeping its CloudEvents type
+	e, err := Decode(data, TypePaymentStatusChanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != "m1" || e.Type != "com.soat.payment.paid" || e.CorrelationID != "c1" || e.Version != PaymentStatusChangedSchemaVersion {
+		t.Errorf("envelope = %+v", e)
+	}
+	var decoded PaymentStatusChangedMessage
+	if err := e.DecodePayload(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != payload {
+		t.Errorf("data = %+v, want %+v", decoded, payload)
+	}
+}
+
+func TestDecodeCloudEventRequiresItsAttributes(t *testing.T) {
+	for _, data := range []string{
+		`{"specversion":"1.0","id":"m1","type":"com.soat.payment.paid","data":{}}`,
+		`{"specversion":"1.0","source":"/payments","type":"com.soat.payment.paid","data":{}}`,
+		`{"specversion":"1.0","id":"m1","source":"/payments","data":{}}`,
+	} {
+		if _, err := Decode([]byte(data), TypePaymentStatusChanged); err == nil {
+			t.Errorf("decoded %s", data)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package messages
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message types carried in the Type field of an Envelope
+const (
+	TypePaymentCreationRequest = "payment.creation_requested"
+	TypePaymentStatusChanged   = "payment.status_changed"
+)
+
+// PaymentStatusChangedSchemaVersion is the current version of PaymentStatusChangedMessage
+const PaymentStatusChangedSchemaVersion = 1
+
+// Envelope wraps every message with the metadata consumers need to dedupe and evolve them
+type Envelope struct {
+	// ID identifies the message, redeliveries of the same message keep it
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Version is the schema version of the payload
+	Version    int       `json:"version"`
+	OccurredAt time.Time `json:"occurred_at"`
+	// CorrelationID is shared by every message of the same business flow
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// CausationID is the ID of the message that caused this one
+	CausationID string          `json:"causation_id,omitempty"`
+	Payload     json.RawMessage `json:"payload"`
+
+	// Bare is set by Decode for messages published without an envelope
+	Bare bool `json:"-"`
+}
+
+// EnvelopeOption customizes the envelope built by NewEnvelope
+type EnvelopeOption func(*Envelope)
+
+// WithID replaces the random message ID
+func WithID(id string) EnvelopeOption {
+	return func(e *Envelope) { e.ID = id }
+}
+
+// WithOccurredAt replaces the current time as the moment the message happened
+func WithOccurredAt(t time.Time) EnvelopeOption {
+	return func(e *Envelope) { e.OccurredAt = t }
+}
+
+// WithCorrelationID sets the correlation ID of the message
+func WithCorrelationID(id string) EnvelopeOption {
+	return func(e *Envelope) { e.CorrelationID = id }
+}
+
+// WithCausationID sets the ID of the message that caused this one
+func WithCausationID(id string) EnvelopeOption {
+	return func(e *Envelope) { e.CausationID = id }
+}
+
+// NewEnvelope wraps payload in an envelope with a random ID and the current time.
+// The correlation ID defaults to the message ID, starting a new flow.
+func NewEnvelope(msgType string, version int, payload any, opts ...EnvelopeOption) (Envelope, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	e := Envelope{
+		ID:         uuid.NewString(),
+		Type:       msgType,
+		Version:    version,
+		OccurredAt: time.Now().UTC(),
+		Payload:    raw,
+	}
+	for _, opt := range opts {
+		opt(&e)
+	}
+	if e.CorrelationID == "" {
+		e.CorrelationID = e.ID
+	}
+	return e, nil
+}
+
+// Encode wraps payload in an envelope and encodes it as JSON
+func Encode(msgType string, version int, payload any, opts ...EnvelopeOption) ([]byte, error) {
+	e, err := NewEnvelope(msgType, version, payload, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(e)
+}
+
+// Decode reads an enveloped message or a CloudEvent, which keeps its CloudEvents type.
+// Messages published in the bare format, the payload alone, are returned wrapped
+// in an envelope of bareType with Bare set and no ID.
+func Decode(data []byte, bareType string) (Envelope, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return Envelope{}, fmt.Errorf("decoding message: %w", err)
+	}
+
+	if _, ok := probe["specversion"]; ok {
+		ce, err := DecodeCloudEvent(data)
+		if err != nil {
+			return Envelope{}, err
+		}
+		return ce.Envelope(), nil
+	}
+
+	_, hasType := probe["type"]
+	_, hasPayload := probe["payload"]
+	if !hasType || !hasPayload {
+		return Envelope{Type: bareType, Payload: json.RawMessage(data), Bare: true}, nil
+	}
+
+	var e Envelope
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Envelope{}, fmt.Errorf("decoding envelope: %w", err)
+	}
+	if e.Type == "" {
+		return Envelope{}, errors.New("decoding envelope: missing type")
+	}
+	return e, nil
+}
+
+// DecodePayload unmarshals the payload of the envelope into v
+func (e Envelope) DecodePayload(v any) error {
+	if len(e.Payload) == 0 {
+		return errors.New("decoding payload: empty payload")
+	}
+	return json.Unmarshal(e.Payload, v)
+}
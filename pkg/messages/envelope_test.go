@@ -0,0 +1,79 @@
+package messages
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	occurredAt := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	payload := PaymentStatusChangedMessage{ID: "p1", OrderID: "o1", Status: "paid"}
+
+	data, err := Encode(TypePaymentStatusChanged, PaymentStatusChangedSchemaVersion, payload,
+		WithID("m1"), WithOccurredAt(occurredAt), WithCausationID("m0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := Decode(data, TypePaymentStatusChanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != "m1" || e.Type != TypePaymentStatusChanged || e.Version != PaymentStatusChangedSchemaVersion || e.Bare {
+		t.Errorf("envelope = %+v", e)
+	}
+	if !e.OccurredAt.Equal(occurredAt) || e.CausationID != "m0" {
+		t.Errorf("occurred at %s caused by %q, want %s caused by m0", e.OccurredAt, e.CausationID, occurredAt)
+	}
+	// a message without a correlation starts a new flow
+	if e.CorrelationID != "m1" {
+		t.Errorf("correlation ID = %q, want the message ID", e.CorrelationID)
+	}
+
+	var decoded PaymentStatusChangedMessage
+	if err := e.DecodePayload(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != payload {
+		t.Errorf("payload = %+v, want %+v", decoded, payload)
+	}
+}
+
+func TestDecodeBareMessage(t *testing.T) {
+	bare := `{"id":"p1","order_id":"o1","status":"Aberto","amount":1990,"currency":"BRL"}`
+	e, err := Decode([]byte(bare), TypePaymentCreationRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.Bare || e.Type != TypePaymentCreationRequest || e.ID != "" {
+		t.Errorf("envelope = %+v, want a bare creation request without an ID", e)
+	}
+	var msg PaymentCreationRequestMessage
+	if err := e.DecodePayload(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID != "p1" || msg.Amount != 1990 {
+		t.Errorf("payload = %+v", msg)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "not JSON", data: `{"id":`},
+		{name: "envelope without a type", data: `{"type":"","payload":{}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if e, err := Decode([]byte(tt.data), TypePaymentStatusChanged); err == nil {
+				t.Errorf("decoded %+v", e)
+			}
+		})
+	}
+
+	if err := (Envelope{}).DecodePayload(&json.RawMessage{}); err == nil {
+		t.Error("decoded an empty payload")
+	}
+}
@@ -1,17 +1,61 @@
 package messages
 
+import (
+	"github.com/SOAT1StackGoLang/msvc-payments/pkg/money"
+)
+
+// PaymentCreationRequestSchemaVersion is the current version of PaymentCreationRequestMessage.
+// Version 1 messages (no schema_version) carry the amount in the float Price field,
+// version 2 messages carry it in Amount, as minor units of Currency.
+const PaymentCreationRequestSchemaVersion = 2
+
 type PaymentCreationRequestMessage struct {
-	ID        string  `json:"id"`
-	CreatedAt string  `json:"created_at"`
-	UpdatedAt string  `json:"updated_at"`
-	Price     float64 `json:"price"`
-	OrderID   string  `json:"order_id"`
-	Status    string  `json:"status"`
+	SchemaVersion int    `json:"schema_version,omitempty"`
+	ID            string `json:"id"`
+	CreatedAt     string `json:"created_at"`
+	UpdatedAt     string `json:"updated_at"`
+	// Deprecated: Price is only read from version 1 messages, use Amount and Currency
+	Price float64 `json:"price,omitempty"`
+	// Amount is the price in minor units of Currency, 1990 with BRL is R$ 19,90
+	Amount   int64  `json:"amount,omitempty"`
+	Currency string `json:"currency,omitempty"`
+	OrderID  string `json:"order_id"`
+	Status   string `json:"status"`
+	// CaptureMode is "manual" to only authorize the payment until it is captured, empty or "automatic" charges it
+	CaptureMode string `json:"capture_mode,omitempty"`
+}
+
+// SetPrice fills the version 2 amount fields from an exact amount.
+// The deprecated Price is filled as well, so consumers still reading version 1 keep working.
+func (m *PaymentCreationRequestMessage) SetPrice(price money.Money) error {
+	minor, err := price.MinorUnits()
+	if err != nil {
+		return err
+	}
+	m.SchemaVersion = PaymentCreationRequestSchemaVersion
+	m.Amount = minor
+	m.Currency = money.NormalizeCurrency(price.Currency)
+	m.Price = price.Amount.InexactFloat64()
+	return nil
+}
+
+// PriceMoney returns the exact price of the message, whatever its schema version.
+// Version 1 prices are rounded by the rule of the default currency.
+func (m PaymentCreationRequestMessage) PriceMoney() (money.Money, error) {
+	if m.SchemaVersion < 2 && m.Amount == 0 {
+		return money.FromFloat(m.Price, m.Currency)
+	}
+	return money.FromMinorUnits(m.Amount, m.Currency)
 }
 
 type PaymentStatusChangedMessage struct {
-	ID        string `json:"id"`
-	OrderID   string `json:"order_id"`
-	Status    string `json:"status"`
-	UpdatedAt string `json:"updated_at"`
+	ID        string       `json:"id"`
+	OrderID   string       `json:"order_id"`
+	Status    string       `json:"status"`
+	UpdatedAt string       `json:"updated_at"`
+	Price     *money.Money `json:"price,omitempty"`
+	// DeclineReason is set on failed payments, such as insufficient_funds or timeout
+	DeclineReason string `json:"decline_reason,omitempty"`
+	// CapturedAmount is set on captured payments, it is less than the price after a partial capture
+	CapturedAmount *money.Money `json:"captured_amount,omitempty"`
 }
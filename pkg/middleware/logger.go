@@ -19,13 +19,21 @@ var (
 )
 
 // InitializeLogger initializes the logger for the microservice.
-// It sets the logger level based on the environment variable "APP_LOG_LEVEL".
-// If the log level is set to "debug", it creates a logger that allows debug logs.
-// Otherwise, it creates a logger that allows error, warn and info level log events to pass.
+// It sets the logger level based on the environment variable "APP_LOG_LEVEL"
+// and the output format based on "APP_LOG_FORMAT".
+// See InitializeLoggerWithOptions for the details.
+func InitializeLogger() {
+	InitializeLoggerWithOptions(os.Getenv("APP_LOG_LEVEL"), os.Getenv("APP_LOG_FORMAT"))
+}
+
+// InitializeLoggerWithOptions initializes the logger for the microservice.
+// If the level is "debug", it creates a logger that allows debug logs, "warn" and "error"
+// restrict the output to those levels, and anything else allows error, warn and info level log events to pass.
+// The format can be "json" for JSON lines, otherwise colored logfmt is used.
 // The logger is configured with a custom timestamp format and caller information.
 // It also creates separate loggers for debug, info, and error logs.
 // Finally, it sets the logger as the output for the standard library log package.
-func InitializeLogger() {
+func InitializeLoggerWithOptions(level string, format string) {
 	// set logger level
 	var logger kitlog.Logger
 
@@ -54,11 +62,20 @@ func InitializeLogger() {
 		return kitlogterm.FgBgColor{}
 	}
 
-	if strings.ToLower(os.Getenv("APP_LOG_LEVEL")) == "debug" {
-		logger = kitlogterm.NewLogger(kitlog.NewSyncWriter(os.Stdout), kitlog.NewLogfmtLogger, colorFn)
-		logger = kitloglevel.NewFilter(logger, kitloglevel.AllowDebug())
+	if strings.ToLower(format) == "json" {
+		logger = kitlog.NewJSONLogger(kitlog.NewSyncWriter(os.Stdout))
 	} else {
 		logger = kitlogterm.NewLogger(kitlog.NewSyncWriter(os.Stdout), kitlog.NewLogfmtLogger, colorFn)
+	}
+
+	switch strings.ToLower(level) {
+	case "debug":
+		logger = kitloglevel.NewFilter(logger, kitloglevel.AllowDebug())
+	case "warn":
+		logger = kitloglevel.NewFilter(logger, kitloglevel.AllowWarn())
+	case "error":
+		logger = kitloglevel.NewFilter(logger, kitloglevel.AllowError())
+	default:
 		logger = kitloglevel.NewFilter(logger, kitloglevel.AllowInfo())
 	}
 
@@ -1,15 +1,16 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/SOAT1StackGoLang/msvc-payments/pkg/api (interfaces: PaymentAPI)
+// Source: github.com/SOAT1StackGoLang/msvc-payments/pkg/api (interfaces: PaymentAPI,PaymentAPIV2)
 //
 // Generated by this command:
 //
-//	mockgen -destination=../mocks/api_mocks.go -package=mocks github.com/SOAT1StackGoLang/msvc-payments/pkg/api PaymentAPI
+//	mockgen -destination=../mocks/api_mocks.go -package=mocks github.com/SOAT1StackGoLang/msvc-payments/pkg/api PaymentAPI,PaymentAPIV2
 //
 
 // Package mocks is a generated GoMock package.
 package mocks
 
 import (
+	context "context"
 	reflect "reflect"
 
 	api "github.com/SOAT1StackGoLang/msvc-payments/pkg/api"
@@ -68,3 +69,176 @@ func (mr *MockPaymentAPIMockRecorder) GetPayment(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPayment", reflect.TypeOf((*MockPaymentAPI)(nil).GetPayment), arg0)
 }
+
+// MockPaymentAPIV2 is a mock of PaymentAPIV2 interface.
+type MockPaymentAPIV2 struct {
+	ctrl     *gomock.Controller
+	recorder *MockPaymentAPIV2MockRecorder
+}
+
+// MockPaymentAPIV2MockRecorder is the mock recorder for MockPaymentAPIV2.
+type MockPaymentAPIV2MockRecorder struct {
+	mock *MockPaymentAPIV2
+}
+
+// NewMockPaymentAPIV2 creates a new mock instance.
+func NewMockPaymentAPIV2(ctrl *gomock.Controller) *MockPaymentAPIV2 {
+	mock := &MockPaymentAPIV2{ctrl: ctrl}
+	mock.recorder = &MockPaymentAPIV2MockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPaymentAPIV2) EXPECT() *MockPaymentAPIV2MockRecorder {
+	return m.recorder
+}
+
+// CapturePayment mocks base method.
+func (m *MockPaymentAPIV2) CapturePayment(arg0 context.Context, arg1 api.CapturePaymentRequest) (api.CapturePaymentResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CapturePayment", arg0, arg1)
+	ret0, _ := ret[0].(api.CapturePaymentResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CapturePayment indicates an expected call of CapturePayment.
+func (mr *MockPaymentAPIV2MockRecorder) CapturePayment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CapturePayment", reflect.TypeOf((*MockPaymentAPIV2)(nil).CapturePayment), arg0, arg1)
+}
+
+// CreatePayment mocks base method.
+func (m *MockPaymentAPIV2) CreatePayment(arg0 context.Context, arg1 api.CreatePaymentRequest) (api.CreatePaymentResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePayment", arg0, arg1)
+	ret0, _ := ret[0].(api.CreatePaymentResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePayment indicates an expected call of CreatePayment.
+func (mr *MockPaymentAPIV2MockRecorder) CreatePayment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePayment", reflect.TypeOf((*MockPaymentAPIV2)(nil).CreatePayment), arg0, arg1)
+}
+
+// CreatePaymentAttempt mocks base method.
+func (m *MockPaymentAPIV2) CreatePaymentAttempt(arg0 context.Context, arg1 api.CreatePaymentAttemptRequest) (api.CreatePaymentAttemptResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePaymentAttempt", arg0, arg1)
+	ret0, _ := ret[0].(api.CreatePaymentAttemptResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePaymentAttempt indicates an expected call of CreatePaymentAttempt.
+func (mr *MockPaymentAPIV2MockRecorder) CreatePaymentAttempt(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePaymentAttempt", reflect.TypeOf((*MockPaymentAPIV2)(nil).CreatePaymentAttempt), arg0, arg1)
+}
+
+// DiscardQuarantinedMessage mocks base method.
+func (m *MockPaymentAPIV2) DiscardQuarantinedMessage(arg0 context.Context, arg1 api.DiscardQuarantinedMessageRequest) (api.DiscardQuarantinedMessageResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DiscardQuarantinedMessage", arg0, arg1)
+	ret0, _ := ret[0].(api.DiscardQuarantinedMessageResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DiscardQuarantinedMessage indicates an expected call of DiscardQuarantinedMessage.
+func (mr *MockPaymentAPIV2MockRecorder) DiscardQuarantinedMessage(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DiscardQuarantinedMessage", reflect.TypeOf((*MockPaymentAPIV2)(nil).DiscardQuarantinedMessage), arg0, arg1)
+}
+
+// GetPayment mocks base method.
+func (m *MockPaymentAPIV2) GetPayment(arg0 context.Context, arg1 api.GetPaymentRequest) (api.GetPaymentResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPayment", arg0, arg1)
+	ret0, _ := ret[0].(api.GetPaymentResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPayment indicates an expected call of GetPayment.
+func (mr *MockPaymentAPIV2MockRecorder) GetPayment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPayment", reflect.TypeOf((*MockPaymentAPIV2)(nil).GetPayment), arg0, arg1)
+}
+
+// GetPaymentsReport mocks base method.
+func (m *MockPaymentAPIV2) GetPaymentsReport(arg0 context.Context) (api.GetPaymentsReportResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPaymentsReport", arg0)
+	ret0, _ := ret[0].(api.GetPaymentsReportResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPaymentsReport indicates an expected call of GetPaymentsReport.
+func (mr *MockPaymentAPIV2MockRecorder) GetPaymentsReport(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPaymentsReport", reflect.TypeOf((*MockPaymentAPIV2)(nil).GetPaymentsReport), arg0)
+}
+
+// ListQuarantinedMessages mocks base method.
+func (m *MockPaymentAPIV2) ListQuarantinedMessages(arg0 context.Context) (api.ListQuarantinedMessagesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListQuarantinedMessages", arg0)
+	ret0, _ := ret[0].(api.ListQuarantinedMessagesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListQuarantinedMessages indicates an expected call of ListQuarantinedMessages.
+func (mr *MockPaymentAPIV2MockRecorder) ListQuarantinedMessages(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListQuarantinedMessages", reflect.TypeOf((*MockPaymentAPIV2)(nil).ListQuarantinedMessages), arg0)
+}
+
+// ReplayQuarantinedMessage mocks base method.
+func (m *MockPaymentAPIV2) ReplayQuarantinedMessage(arg0 context.Context, arg1 api.ReplayQuarantinedMessageRequest) (api.ReplayQuarantinedMessageResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplayQuarantinedMessage", arg0, arg1)
+	ret0, _ := ret[0].(api.ReplayQuarantinedMessageResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplayQuarantinedMessage indicates an expected call of ReplayQuarantinedMessage.
+func (mr *MockPaymentAPIV2MockRecorder) ReplayQuarantinedMessage(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplayQuarantinedMessage", reflect.TypeOf((*MockPaymentAPIV2)(nil).ReplayQuarantinedMessage), arg0, arg1)
+}
+
+// UpdatePayment mocks base method.
+func (m *MockPaymentAPIV2) UpdatePayment(arg0 context.Context, arg1 api.UpdatePaymentRequest) (api.UpdatePaymentResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePayment", arg0, arg1)
+	ret0, _ := ret[0].(api.UpdatePaymentResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdatePayment indicates an expected call of UpdatePayment.
+func (mr *MockPaymentAPIV2MockRecorder) UpdatePayment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePayment", reflect.TypeOf((*MockPaymentAPIV2)(nil).UpdatePayment), arg0, arg1)
+}
+
+// VoidPayment mocks base method.
+func (m *MockPaymentAPIV2) VoidPayment(arg0 context.Context, arg1 api.VoidPaymentRequest) (api.VoidPaymentResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VoidPayment", arg0, arg1)
+	ret0, _ := ret[0].(api.VoidPaymentResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VoidPayment indicates an expected call of VoidPayment.
+func (mr *MockPaymentAPIV2MockRecorder) VoidPayment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VoidPayment", reflect.TypeOf((*MockPaymentAPIV2)(nil).VoidPayment), arg0, arg1)
+}
@@ -1,9 +1,9 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore (interfaces: RedisStore)
+// Source: github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore (interfaces: RedisStore,PaymentRepository,WorkQueue,MessageBus,PaymentCreator,Inbox)
 //
 // Generated by this command:
 //
-//	mockgen -destination=../mocks/datastore_mocks.go -package=mocks github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore RedisStore
+//	mockgen -destination=../mocks/datastore_mocks.go -package=mocks github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore RedisStore,PaymentRepository,WorkQueue,MessageBus,PaymentCreator,Inbox
 //
 
 // Package mocks is a generated GoMock package.
@@ -14,7 +14,7 @@ import (
 	reflect "reflect"
 	time "time"
 
-	redis "github.com/redis/go-redis/v9"
+	datastore "github.com/SOAT1StackGoLang/msvc-payments/pkg/datastore"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -85,6 +85,36 @@ func (mr *MockRedisStoreMockRecorder) CloseClient() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseClient", reflect.TypeOf((*MockRedisStore)(nil).CloseClient))
 }
 
+// CompareAndSwap mocks base method.
+func (m *MockRedisStore) CompareAndSwap(arg0 context.Context, arg1, arg2 string, arg3 any) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompareAndSwap", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompareAndSwap indicates an expected call of CompareAndSwap.
+func (mr *MockRedisStoreMockRecorder) CompareAndSwap(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareAndSwap", reflect.TypeOf((*MockRedisStore)(nil).CompareAndSwap), arg0, arg1, arg2, arg3)
+}
+
+// CreateAndEnqueue mocks base method.
+func (m *MockRedisStore) CreateAndEnqueue(arg0 context.Context, arg1 datastore.CreateOp) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAndEnqueue", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAndEnqueue indicates an expected call of CreateAndEnqueue.
+func (mr *MockRedisStoreMockRecorder) CreateAndEnqueue(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAndEnqueue", reflect.TypeOf((*MockRedisStore)(nil).CreateAndEnqueue), arg0, arg1)
+}
+
 // Delete mocks base method.
 func (m *MockRedisStore) Delete(arg0 context.Context, arg1 string) error {
 	m.ctrl.T.Helper()
@@ -114,6 +144,20 @@ func (mr *MockRedisStoreMockRecorder) Exists(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exists", reflect.TypeOf((*MockRedisStore)(nil).Exists), arg0, arg1)
 }
 
+// Forget mocks base method.
+func (m *MockRedisStore) Forget(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Forget", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Forget indicates an expected call of Forget.
+func (mr *MockRedisStoreMockRecorder) Forget(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Forget", reflect.TypeOf((*MockRedisStore)(nil).Forget), arg0, arg1)
+}
+
 // Get mocks base method.
 func (m *MockRedisStore) Get(arg0 context.Context, arg1 string) (string, error) {
 	m.ctrl.T.Helper()
@@ -186,6 +230,36 @@ func (mr *MockRedisStoreMockRecorder) LRange(arg0, arg1, arg2, arg3 any) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LRange", reflect.TypeOf((*MockRedisStore)(nil).LRange), arg0, arg1, arg2, arg3)
 }
 
+// List mocks base method.
+func (m *MockRedisStore) List(arg0 context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockRedisStoreMockRecorder) List(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockRedisStore)(nil).List), arg0)
+}
+
+// MarkProcessed mocks base method.
+func (m *MockRedisStore) MarkProcessed(arg0 context.Context, arg1 string, arg2 time.Duration) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkProcessed", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkProcessed indicates an expected call of MarkProcessed.
+func (mr *MockRedisStoreMockRecorder) MarkProcessed(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkProcessed", reflect.TypeOf((*MockRedisStore)(nil).MarkProcessed), arg0, arg1, arg2)
+}
+
 // Publish mocks base method.
 func (m *MockRedisStore) Publish(arg0 context.Context, arg1 string, arg2 any) error {
 	m.ctrl.T.Helper()
@@ -229,10 +303,10 @@ func (mr *MockRedisStoreMockRecorder) Set(arg0, arg1, arg2, arg3 any) *gomock.Ca
 }
 
 // Subscribe mocks base method.
-func (m *MockRedisStore) Subscribe(arg0 context.Context, arg1 string) (<-chan *redis.Message, error) {
+func (m *MockRedisStore) Subscribe(arg0 context.Context, arg1 string) (<-chan *datastore.Message, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Subscribe", arg0, arg1)
-	ret0, _ := ret[0].(<-chan *redis.Message)
+	ret0, _ := ret[0].(<-chan *datastore.Message)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -244,10 +318,10 @@ func (mr *MockRedisStoreMockRecorder) Subscribe(arg0, arg1 any) *gomock.Call {
 }
 
 // SubscribeLog mocks base method.
-func (m *MockRedisStore) SubscribeLog(arg0 context.Context) (<-chan *redis.Message, error) {
+func (m *MockRedisStore) SubscribeLog(arg0 context.Context) (<-chan *datastore.Message, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "SubscribeLog", arg0)
-	ret0, _ := ret[0].(<-chan *redis.Message)
+	ret0, _ := ret[0].(<-chan *datastore.Message)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -257,3 +331,395 @@ func (mr *MockRedisStoreMockRecorder) SubscribeLog(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeLog", reflect.TypeOf((*MockRedisStore)(nil).SubscribeLog), arg0)
 }
+
+// MockPaymentRepository is a mock of PaymentRepository interface.
+type MockPaymentRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockPaymentRepositoryMockRecorder
+}
+
+// MockPaymentRepositoryMockRecorder is the mock recorder for MockPaymentRepository.
+type MockPaymentRepositoryMockRecorder struct {
+	mock *MockPaymentRepository
+}
+
+// NewMockPaymentRepository creates a new mock instance.
+func NewMockPaymentRepository(ctrl *gomock.Controller) *MockPaymentRepository {
+	mock := &MockPaymentRepository{ctrl: ctrl}
+	mock.recorder = &MockPaymentRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPaymentRepository) EXPECT() *MockPaymentRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CompareAndSwap mocks base method.
+func (m *MockPaymentRepository) CompareAndSwap(arg0 context.Context, arg1, arg2 string, arg3 any) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompareAndSwap", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompareAndSwap indicates an expected call of CompareAndSwap.
+func (mr *MockPaymentRepositoryMockRecorder) CompareAndSwap(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareAndSwap", reflect.TypeOf((*MockPaymentRepository)(nil).CompareAndSwap), arg0, arg1, arg2, arg3)
+}
+
+// Delete mocks base method.
+func (m *MockPaymentRepository) Delete(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockPaymentRepositoryMockRecorder) Delete(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockPaymentRepository)(nil).Delete), arg0, arg1)
+}
+
+// Exists mocks base method.
+func (m *MockPaymentRepository) Exists(arg0 context.Context, arg1 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Exists", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Exists indicates an expected call of Exists.
+func (mr *MockPaymentRepositoryMockRecorder) Exists(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exists", reflect.TypeOf((*MockPaymentRepository)(nil).Exists), arg0, arg1)
+}
+
+// Get mocks base method.
+func (m *MockPaymentRepository) Get(arg0 context.Context, arg1 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockPaymentRepositoryMockRecorder) Get(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockPaymentRepository)(nil).Get), arg0, arg1)
+}
+
+// List mocks base method.
+func (m *MockPaymentRepository) List(arg0 context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockPaymentRepositoryMockRecorder) List(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockPaymentRepository)(nil).List), arg0)
+}
+
+// Set mocks base method.
+func (m *MockPaymentRepository) Set(arg0 context.Context, arg1 string, arg2 any, arg3 time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set.
+func (mr *MockPaymentRepositoryMockRecorder) Set(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockPaymentRepository)(nil).Set), arg0, arg1, arg2, arg3)
+}
+
+// MockWorkQueue is a mock of WorkQueue interface.
+type MockWorkQueue struct {
+	ctrl     *gomock.Controller
+	recorder *MockWorkQueueMockRecorder
+}
+
+// MockWorkQueueMockRecorder is the mock recorder for MockWorkQueue.
+type MockWorkQueueMockRecorder struct {
+	mock *MockWorkQueue
+}
+
+// NewMockWorkQueue creates a new mock instance.
+func NewMockWorkQueue(ctrl *gomock.Controller) *MockWorkQueue {
+	mock := &MockWorkQueue{ctrl: ctrl}
+	mock.recorder = &MockWorkQueueMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWorkQueue) EXPECT() *MockWorkQueueMockRecorder {
+	return m.recorder
+}
+
+// BLMOVE mocks base method.
+func (m *MockWorkQueue) BLMOVE(arg0 context.Context, arg1, arg2 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BLMOVE", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BLMOVE indicates an expected call of BLMOVE.
+func (mr *MockWorkQueueMockRecorder) BLMOVE(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BLMOVE", reflect.TypeOf((*MockWorkQueue)(nil).BLMOVE), arg0, arg1, arg2)
+}
+
+// BRPop mocks base method.
+func (m *MockWorkQueue) BRPop(arg0 context.Context, arg1 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BRPop", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BRPop indicates an expected call of BRPop.
+func (mr *MockWorkQueueMockRecorder) BRPop(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BRPop", reflect.TypeOf((*MockWorkQueue)(nil).BRPop), arg0, arg1)
+}
+
+// LIndex mocks base method.
+func (m *MockWorkQueue) LIndex(arg0 context.Context, arg1 string, arg2 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LIndex", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LIndex indicates an expected call of LIndex.
+func (mr *MockWorkQueueMockRecorder) LIndex(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LIndex", reflect.TypeOf((*MockWorkQueue)(nil).LIndex), arg0, arg1, arg2)
+}
+
+// LPush mocks base method.
+func (m *MockWorkQueue) LPush(arg0 context.Context, arg1 string, arg2 any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LPush", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LPush indicates an expected call of LPush.
+func (mr *MockWorkQueueMockRecorder) LPush(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LPush", reflect.TypeOf((*MockWorkQueue)(nil).LPush), arg0, arg1, arg2)
+}
+
+// LREM mocks base method.
+func (m *MockWorkQueue) LREM(arg0 context.Context, arg1 string, arg2 int64, arg3 any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LREM", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LREM indicates an expected call of LREM.
+func (mr *MockWorkQueueMockRecorder) LREM(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LREM", reflect.TypeOf((*MockWorkQueue)(nil).LREM), arg0, arg1, arg2, arg3)
+}
+
+// LRange mocks base method.
+func (m *MockWorkQueue) LRange(arg0 context.Context, arg1 string, arg2, arg3 int64) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LRange", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LRange indicates an expected call of LRange.
+func (mr *MockWorkQueueMockRecorder) LRange(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LRange", reflect.TypeOf((*MockWorkQueue)(nil).LRange), arg0, arg1, arg2, arg3)
+}
+
+// RPush mocks base method.
+func (m *MockWorkQueue) RPush(arg0 context.Context, arg1 string, arg2 any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RPush", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RPush indicates an expected call of RPush.
+func (mr *MockWorkQueueMockRecorder) RPush(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RPush", reflect.TypeOf((*MockWorkQueue)(nil).RPush), arg0, arg1, arg2)
+}
+
+// MockMessageBus is a mock of MessageBus interface.
+type MockMessageBus struct {
+	ctrl     *gomock.Controller
+	recorder *MockMessageBusMockRecorder
+}
+
+// MockMessageBusMockRecorder is the mock recorder for MockMessageBus.
+type MockMessageBusMockRecorder struct {
+	mock *MockMessageBus
+}
+
+// NewMockMessageBus creates a new mock instance.
+func NewMockMessageBus(ctrl *gomock.Controller) *MockMessageBus {
+	mock := &MockMessageBus{ctrl: ctrl}
+	mock.recorder = &MockMessageBusMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMessageBus) EXPECT() *MockMessageBusMockRecorder {
+	return m.recorder
+}
+
+// Publish mocks base method.
+func (m *MockMessageBus) Publish(arg0 context.Context, arg1 string, arg2 any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Publish", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockMessageBusMockRecorder) Publish(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockMessageBus)(nil).Publish), arg0, arg1, arg2)
+}
+
+// Subscribe mocks base method.
+func (m *MockMessageBus) Subscribe(arg0 context.Context, arg1 string) (<-chan *datastore.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscribe", arg0, arg1)
+	ret0, _ := ret[0].(<-chan *datastore.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Subscribe indicates an expected call of Subscribe.
+func (mr *MockMessageBusMockRecorder) Subscribe(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockMessageBus)(nil).Subscribe), arg0, arg1)
+}
+
+// SubscribeLog mocks base method.
+func (m *MockMessageBus) SubscribeLog(arg0 context.Context) (<-chan *datastore.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeLog", arg0)
+	ret0, _ := ret[0].(<-chan *datastore.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubscribeLog indicates an expected call of SubscribeLog.
+func (mr *MockMessageBusMockRecorder) SubscribeLog(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeLog", reflect.TypeOf((*MockMessageBus)(nil).SubscribeLog), arg0)
+}
+
+// MockPaymentCreator is a mock of PaymentCreator interface.
+type MockPaymentCreator struct {
+	ctrl     *gomock.Controller
+	recorder *MockPaymentCreatorMockRecorder
+}
+
+// MockPaymentCreatorMockRecorder is the mock recorder for MockPaymentCreator.
+type MockPaymentCreatorMockRecorder struct {
+	mock *MockPaymentCreator
+}
+
+// NewMockPaymentCreator creates a new mock instance.
+func NewMockPaymentCreator(ctrl *gomock.Controller) *MockPaymentCreator {
+	mock := &MockPaymentCreator{ctrl: ctrl}
+	mock.recorder = &MockPaymentCreatorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPaymentCreator) EXPECT() *MockPaymentCreatorMockRecorder {
+	return m.recorder
+}
+
+// CreateAndEnqueue mocks base method.
+func (m *MockPaymentCreator) CreateAndEnqueue(arg0 context.Context, arg1 datastore.CreateOp) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAndEnqueue", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAndEnqueue indicates an expected call of CreateAndEnqueue.
+func (mr *MockPaymentCreatorMockRecorder) CreateAndEnqueue(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAndEnqueue", reflect.TypeOf((*MockPaymentCreator)(nil).CreateAndEnqueue), arg0, arg1)
+}
+
+// MockInbox is a mock of Inbox interface.
+type MockInbox struct {
+	ctrl     *gomock.Controller
+	recorder *MockInboxMockRecorder
+}
+
+// MockInboxMockRecorder is the mock recorder for MockInbox.
+type MockInboxMockRecorder struct {
+	mock *MockInbox
+}
+
+// NewMockInbox creates a new mock instance.
+func NewMockInbox(ctrl *gomock.Controller) *MockInbox {
+	mock := &MockInbox{ctrl: ctrl}
+	mock.recorder = &MockInboxMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockInbox) EXPECT() *MockInboxMockRecorder {
+	return m.recorder
+}
+
+// Forget mocks base method.
+func (m *MockInbox) Forget(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Forget", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Forget indicates an expected call of Forget.
+func (mr *MockInboxMockRecorder) Forget(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Forget", reflect.TypeOf((*MockInbox)(nil).Forget), arg0, arg1)
+}
+
+// MarkProcessed mocks base method.
+func (m *MockInbox) MarkProcessed(arg0 context.Context, arg1 string, arg2 time.Duration) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkProcessed", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkProcessed indicates an expected call of MarkProcessed.
+func (mr *MockInboxMockRecorder) MarkProcessed(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkProcessed", reflect.TypeOf((*MockInbox)(nil).MarkProcessed), arg0, arg1, arg2)
+}
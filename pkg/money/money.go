@@ -0,0 +1,206 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultCurrency is assumed for amounts that were sent without a currency
+const DefaultCurrency = "BRL"
+
+// ErrCurrencyMismatch is returned when two amounts of different currencies are combined or compared
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// Rule describes how amounts of a currency are rounded
+type Rule struct {
+	// Exponent is the number of minor unit digits, 2 for BRL and 0 for JPY
+	Exponent int32
+	// Increment is the smallest amount that can be charged when it is coarser than one minor unit,
+	// such as 0.05 for CHF, zero means one minor unit
+	Increment decimal.Decimal
+}
+
+// rules holds the rounding rules of the supported ISO-4217 currencies
+var rules = map[string]Rule{
+	"BRL": {Exponent: 2},
+	"USD": {Exponent: 2},
+	"EUR": {Exponent: 2},
+	"GBP": {Exponent: 2},
+	"ARS": {Exponent: 2},
+	"MXN": {Exponent: 2},
+	"CHF": {Exponent: 2, Increment: decimal.New(5, -2)},
+	"JPY": {Exponent: 0},
+	"CLP": {Exponent: 0},
+	"PYG": {Exponent: 0},
+	"KWD": {Exponent: 3},
+	"BHD": {Exponent: 3},
+}
+
+// NormalizeCurrency upper-cases a currency code and falls back to DefaultCurrency when it is empty
+func NormalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return DefaultCurrency
+	}
+	return currency
+}
+
+// RuleFor returns the rounding rule of a currency
+func RuleFor(currency string) (Rule, error) {
+	rule, ok := rules[NormalizeCurrency(currency)]
+	if !ok {
+		return Rule{}, fmt.Errorf("unsupported currency %q", currency)
+	}
+	return rule, nil
+}
+
+// Exponent returns the number of minor unit digits of a currency, 2 for BRL and 0 for JPY
+func Exponent(currency string) (int32, error) {
+	rule, err := RuleFor(currency)
+	return rule.Exponent, err
+}
+
+// Money is an exact amount in a currency
+type Money struct {
+	Amount   decimal.Decimal `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// New creates an amount of a supported currency rounded by the currency rule
+func New(amount decimal.Decimal, currency string) (Money, error) {
+	m := Money{Amount: amount, Currency: NormalizeCurrency(currency)}
+	return m.Round()
+}
+
+// FromMinorUnits converts an integer amount of minor units into Money, 1990 BRL is 19.90
+func FromMinorUnits(amount int64, currency string) (Money, error) {
+	exp, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: decimal.New(amount, -exp), Currency: NormalizeCurrency(currency)}, nil
+}
+
+// FromFloat converts a legacy float amount rounding it by the currency rule,
+// so 19.899999999 becomes 19.90 for BRL
+func FromFloat(amount float64, currency string) (Money, error) {
+	return New(decimal.NewFromFloat(amount), currency)
+}
+
+// Round rounds the amount half away from zero to the minor units, or to the increment, of its currency
+func (m Money) Round() (Money, error) {
+	rule, err := RuleFor(m.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+	m.Currency = NormalizeCurrency(m.Currency)
+	if rule.Increment.IsPositive() {
+		m.Amount = m.Amount.Div(rule.Increment).Round(0).Mul(rule.Increment)
+	}
+	m.Amount = m.Amount.Round(rule.Exponent)
+	return m, nil
+}
+
+// Rounded reports whether the amount already respects the rule of its currency
+func (m Money) Rounded() bool {
+	rounded, err := m.Round()
+	return err == nil && rounded.Amount.Equal(m.Amount)
+}
+
+// MinorUnits returns the amount as an integer of minor units, failing if it has more digits than the currency allows
+func (m Money) MinorUnits() (int64, error) {
+	exp, err := Exponent(m.Currency)
+	if err != nil {
+		return 0, err
+	}
+	minor := m.Amount.Shift(exp)
+	if !minor.Equal(minor.Truncate(0)) {
+		return 0, fmt.Errorf("amount %s has more than %d decimal places for %s", m.Amount, exp, NormalizeCurrency(m.Currency))
+	}
+	return minor.IntPart(), nil
+}
+
+// SameCurrency reports whether both amounts are in the same currency
+func (m Money) SameCurrency(other Money) bool {
+	return NormalizeCurrency(m.Currency) == NormalizeCurrency(other.Currency)
+}
+
+// Add sums two amounts of the same currency
+func (m Money) Add(other Money) (Money, error) {
+	if !m.SameCurrency(other) {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	return Money{Amount: m.Amount.Add(other.Amount), Currency: NormalizeCurrency(m.Currency)}, nil
+}
+
+// Sub subtracts an amount of the same currency
+func (m Money) Sub(other Money) (Money, error) {
+	if !m.SameCurrency(other) {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	return Money{Amount: m.Amount.Sub(other.Amount), Currency: NormalizeCurrency(m.Currency)}, nil
+}
+
+// Cmp compares two amounts of the same currency, returning -1, 0 or 1
+func (m Money) Cmp(other Money) (int, error) {
+	if !m.SameCurrency(other) {
+		return 0, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	return m.Amount.Cmp(other.Amount), nil
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Amount.IsZero()
+}
+
+// String formats the amount with the digits of its currency, "19.90 BRL"
+func (m Money) String() string {
+	exp, err := Exponent(m.Currency)
+	if err != nil {
+		return m.Amount.String() + " " + m.Currency
+	}
+	return m.Amount.StringFixed(exp) + " " + NormalizeCurrency(m.Currency)
+}
+
+// MarshalJSON encodes the amount as a decimal string with the digits of its currency, {"amount": "19.90", "currency": "BRL"}
+func (m Money) MarshalJSON() ([]byte, error) {
+	amount := m.Amount.String()
+	if exp, err := Exponent(m.Currency); err == nil && m.Amount.Exponent() >= -exp {
+		amount = m.Amount.StringFixed(exp)
+	}
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{Amount: amount, Currency: m.Currency})
+}
+
+// UnmarshalJSON decodes {"amount": "19.90", "currency": "BRL"} as well as the bare decimal
+// amounts, "19.90" or 19.90, written before amounts had a currency, which are read as DefaultCurrency
+func (m *Money) UnmarshalJSON(data []byte) error {
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "null" {
+		return nil
+	}
+	if !strings.HasPrefix(trimmed, "{") {
+		var amount decimal.Decimal
+		if err := amount.UnmarshalJSON(data); err != nil {
+			return err
+		}
+		*m = Money{Amount: amount, Currency: DefaultCurrency}
+		return nil
+	}
+
+	type plain Money
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = Money(decoded)
+	m.Currency = NormalizeCurrency(m.Currency)
+	return nil
+}
@@ -0,0 +1,173 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestNewRoundsHalfAwayFromZero(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     string
+	}{
+		{amount: "19.895", currency: "BRL", want: "19.9"},
+		{amount: "19.894", currency: "BRL", want: "19.89"},
+		{amount: "-19.895", currency: "BRL", want: "-19.9"},
+		{amount: "0.005", currency: "brl", want: "0.01"},
+		{amount: "1234.5", currency: "JPY", want: "1235"},
+		{amount: "1234.4", currency: "JPY", want: "1234"},
+		{amount: "-1234.5", currency: "JPY", want: "-1235"},
+		{amount: "1.2345", currency: "KWD", want: "1.235"},
+		{amount: "1.2344", currency: "KWD", want: "1.234"},
+		{amount: "0.0005", currency: "BHD", want: "0.001"},
+		{amount: "1.025", currency: "CHF", want: "1.05"},
+		{amount: "1.024", currency: "CHF", want: "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.amount+" "+tt.currency, func(t *testing.T) {
+			got, err := New(decimal.RequireFromString(tt.amount), tt.currency)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Amount.Equal(decimal.RequireFromString(tt.want)) {
+				t.Errorf("New(%s, %s) = %s, want %s", tt.amount, tt.currency, got.Amount, tt.want)
+			}
+			if !got.Rounded() {
+				t.Errorf("%s is not rounded", got)
+			}
+		})
+	}
+
+	if _, err := New(decimal.NewFromInt(1), "XYZ"); err == nil {
+		t.Error("New accepted an unsupported currency")
+	}
+}
+
+func TestMinorUnits(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     int64
+		wantErr  bool
+	}{
+		{amount: "19.90", currency: "BRL", want: 1990},
+		{amount: "1235", currency: "JPY", want: 1235},
+		{amount: "1.235", currency: "KWD", want: 1235},
+		{amount: "19.905", currency: "BRL", wantErr: true},
+		{amount: "0.5", currency: "JPY", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.amount+" "+tt.currency, func(t *testing.T) {
+			got, err := Money{Amount: decimal.RequireFromString(tt.amount), Currency: tt.currency}.MinorUnits()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MinorUnits = %d, want %d", got, tt.want)
+			}
+
+			if tt.wantErr {
+				return
+			}
+			back, err := FromMinorUnits(got, tt.currency)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !back.Amount.Equal(decimal.RequireFromString(tt.amount)) {
+				t.Errorf("FromMinorUnits(%d) = %s, want %s", got, back.Amount, tt.amount)
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		json      string
+		want      Money
+		wantJSON  string
+		wantError bool
+	}{
+		{
+			name:     "amount with a currency",
+			json:     `{"amount":"19.90","currency":"BRL"}`,
+			want:     Money{Amount: decimal.RequireFromString("19.90"), Currency: "BRL"},
+			wantJSON: `{"amount":"19.90","currency":"BRL"}`,
+		},
+		{
+			name:     "currency is normalized",
+			json:     `{"amount":"1235","currency":"jpy"}`,
+			want:     Money{Amount: decimal.RequireFromString("1235"), Currency: "JPY"},
+			wantJSON: `{"amount":"1235","currency":"JPY"}`,
+		},
+		{
+			name:     "three decimal currency keeps its digits",
+			json:     `{"amount":"1.2","currency":"KWD"}`,
+			want:     Money{Amount: decimal.RequireFromString("1.2"), Currency: "KWD"},
+			wantJSON: `{"amount":"1.200","currency":"KWD"}`,
+		},
+		{
+			name:     "missing currency is the default one",
+			json:     `{"amount":"5"}`,
+			want:     Money{Amount: decimal.RequireFromString("5"), Currency: DefaultCurrency},
+			wantJSON: `{"amount":"5.00","currency":"BRL"}`,
+		},
+		{
+			name:     "bare number is read as BRL",
+			json:     `19.90`,
+			want:     Money{Amount: decimal.RequireFromString("19.90"), Currency: "BRL"},
+			wantJSON: `{"amount":"19.90","currency":"BRL"}`,
+		},
+		{
+			name:     "bare string is read as BRL",
+			json:     `"19.90"`,
+			want:     Money{Amount: decimal.RequireFromString("19.90"), Currency: "BRL"},
+			wantJSON: `{"amount":"19.90","currency":"BRL"}`,
+		},
+		{
+			name:     "more digits than the currency are kept",
+			json:     `{"amount":"19.905","currency":"BRL"}`,
+			want:     Money{Amount: decimal.RequireFromString("19.905"), Currency: "BRL"},
+			wantJSON: `{"amount":"19.905","currency":"BRL"}`,
+		},
+		{
+			name:      "invalid amount",
+			json:      `"abc"`,
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Money
+			err := json.Unmarshal([]byte(tt.json), &got)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("err = %v, want error %v", err, tt.wantError)
+			}
+			if tt.wantError {
+				return
+			}
+			if !got.Amount.Equal(tt.want.Amount) || got.Currency != tt.want.Currency {
+				t.Errorf("decoded %s, want %s", got, tt.want)
+			}
+
+			encoded, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(encoded) != tt.wantJSON {
+				t.Errorf("encoded %s, want %s", encoded, tt.wantJSON)
+			}
+
+			var again Money
+			if err := json.Unmarshal(encoded, &again); err != nil {
+				t.Fatal(err)
+			}
+			if !again.Amount.Equal(got.Amount) || again.Currency != got.Currency {
+				t.Errorf("round trip gave %s, want %s", again, got)
+			}
+		})
+	}
+}